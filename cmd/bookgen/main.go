@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"runtime"
+	"time"
+
+	"emitrr-4-in-a-row/internal/game"
+)
+
+// bookgen builds the opening book used by the perfect bot:
+//
+//	go run ./cmd/bookgen -depth 8 -out data/opening-book.bin
+func main() {
+	depth := flag.Int("depth", 8, "number of plies covered by the book")
+	out := flag.String("out", "data/opening-book.bin", "output file")
+	workers := flag.Int("workers", runtime.NumCPU(), "parallel solvers")
+	tableSizeMB := flag.Int("table-mb", 64, "transposition table size per solver")
+	flag.Parse()
+
+	start := time.Now()
+	lastReport := start
+	book := game.BuildOpeningBook(*depth, *workers, *tableSizeMB, func(done, total int) {
+		if time.Since(lastReport) >= 10*time.Second || done == total {
+			lastReport = time.Now()
+			log.Printf("Solved %d/%d positions (%s)", done, total, time.Since(start).Round(time.Second))
+		}
+	})
+
+	if err := book.Save(*out); err != nil {
+		log.Fatalf("Failed to write opening book: %v", err)
+	}
+	log.Printf("Opening book written to %s: %d positions, depth %d", *out, book.Len(), book.Depth())
+}
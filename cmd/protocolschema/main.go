@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"emitrr-4-in-a-row/internal/protocol"
+)
+
+// protocolschema writes the JSON Schema of the WebSocket protocol, for
+// clients that validate messages or generate types from it:
+//
+//	go run ./cmd/protocolschema -out protocol.schema.json
+func main() {
+	out := flag.String("out", "", "output file, stdout if empty")
+	flag.Parse()
+
+	schema, err := json.MarshalIndent(protocol.Schema(), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode schema: %v", err)
+	}
+	schema = append(schema, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*out, schema, 0o644); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+	log.Printf("Protocol v%d schema written to %s", protocol.Version, *out)
+}
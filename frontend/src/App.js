@@ -39,13 +39,14 @@ function App() {
       // Auto-reconnect if we have stored game info
       const storedUsername = localStorage.getItem('gameUsername');
       const storedGameId = localStorage.getItem('gameId');
-      if (storedUsername && storedGameId && !reconnectAttempted) {
+      const storedToken = localStorage.getItem('sessionToken');
+      if (storedUsername && storedGameId && storedToken && !reconnectAttempted) {
         setReconnectAttempted(true);
         setUsername(storedUsername);
         console.log('Attempting auto-reconnect:', { username: storedUsername, gameId: storedGameId });
         socket.send(JSON.stringify({
           type: 'rejoin_game',
-          data: { username: storedUsername, gameId: storedGameId }
+          data: { sessionToken: storedToken }
         }));
       }
     };
@@ -108,6 +109,7 @@ function App() {
           // Store game info for reconnection
           localStorage.setItem('gameUsername', username);
           localStorage.setItem('gameId', data.gameState.id);
+          localStorage.setItem('sessionToken', data.sessionToken);
           break;
         case 'your_turn':
           setYourPlayer(data.player);
@@ -127,6 +129,7 @@ function App() {
           // Clear stored game info
           localStorage.removeItem('gameUsername');
           localStorage.removeItem('gameId');
+          localStorage.removeItem('sessionToken');
           if (data.winner === null) {
             setMessage('Game ended in a draw!');
           } else if (data.winner === yourPlayer) {
@@ -154,9 +157,10 @@ function App() {
         case 'error':
           setMessage(`Error: ${data.message}`);
           // Clear reconnection data if error is about game not found
-          if (data.message.includes('No active game') || data.message.includes('not found') || data.message.includes('rejoin')) {
+          if (data.message.includes('No active game') || data.message.includes('not found') || data.message.includes('rejoin') || data.message.includes('session token')) {
             localStorage.removeItem('gameUsername');
             localStorage.removeItem('gameId');
+            localStorage.removeItem('sessionToken');
             setDisconnectedGameId(null);
             setReconnectAttempted(false);
           }
@@ -180,11 +184,12 @@ function App() {
   };
 
   const rejoinGame = () => {
-    if (username.trim() && disconnectedGameId && socket && socket.readyState === WebSocket.OPEN) {
+    const sessionToken = localStorage.getItem('sessionToken');
+    if (sessionToken && disconnectedGameId && socket && socket.readyState === WebSocket.OPEN) {
       console.log('Attempting to rejoin game:', { username: username.trim(), gameId: disconnectedGameId });
       socket.send(JSON.stringify({
         type: 'rejoin_game',
-        data: { username: username.trim(), gameId: disconnectedGameId }
+        data: { sessionToken }
       }));
     }
   };
@@ -219,6 +224,7 @@ function App() {
     // Clear stored game info
     localStorage.removeItem('gameUsername');
     localStorage.removeItem('gameId');
+    localStorage.removeItem('sessionToken');
   };
 
   const toggleLeaderboard = () => {
@@ -75,6 +75,7 @@ const Leaderboard = () => {
           <div className="table-header">
             <div className="rank">Rank</div>
             <div className="username">Player</div>
+            <div className="rating">Rating</div>
             <div className="wins">Wins</div>
             <div className="games">Games</div>
             <div className="winrate">Win Rate</div>
@@ -90,6 +91,10 @@ const Leaderboard = () => {
                 {index > 2 && `#${index + 1}`}
               </div>
               <div className="username">{player.username}</div>
+              <div className="rating">
+                {player.rating}
+                {player.provisional && <span className="provisional" title="Provisional rating">?</span>}
+              </div>
               <div className="wins">{player.games_won}</div>
               <div className="games">{player.games_played}</div>
               <div className="winrate">{player.win_rate}%</div>
@@ -2,39 +2,92 @@ package config
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port         string
-	DBHost       string
-	DBPort       string
-	DBName       string
-	DBUser       string
-	DBPassword   string
-	DatabaseURL  string
-
-	RedisURL     string
-	KafkaBroker  string
-	NodeEnv      string
+	Port        string
+	DBHost      string
+	DBPort      string
+	DBName      string
+	DBUser      string
+	DBPassword  string
+	DatabaseURL string
+
+	StorageDriver string
+	SQLitePath    string
+
+	RedisURL    string
+	KafkaBroker string
+	NodeEnv     string
+
+	BotTableSizeMB   int
+	BotMemoryLimitMB int
+	OpeningBookPath  string
+	BotThinkTime     string // per difficulty in milliseconds, e.g. "strong=1500,perfect=3000"
+	BotMoveDelay     string // per difficulty pause in milliseconds before the bot answers
+
+	MatchmakingBotTimeout   int // seconds in the queue before a bot game starts
+	MatchmakingWindow       int // rating gap accepted straight away
+	MatchmakingWindowGrowth int // extra rating gap accepted per second waited
+	MatchmakingMaxWindow    int
+
+	RoomExpiryMinutes int // how long a private room waits for the invitee
+
+	TimeControl string // e.g. "3+2" or "30/move"; "none" for untimed games
+
+	EventLogSize int // events kept per game for clients catching up after a reconnect
+
+	PingInterval   int // seconds between heartbeat pings; 0 turns them off
+	PongTimeout    int // seconds a ping may go unanswered before the connection counts as dead
+	MaxMessageSize int // bytes; bigger client frames close the connection
+
+	SessionSecret string // signs reconnect tokens; a random key is used when empty
 }
 
 func Load() *Config {
 	godotenv.Load()
 
 	return &Config{
-		Port:         getEnv("PORT", "3001"),
-		DBHost:       getEnv("DB_HOST", "localhost"),
-		DBPort:       getEnv("DB_PORT", "5432"),
-		DBName:       getEnv("DB_NAME", "four_in_a_row"),
-		DBUser:       getEnv("DB_USER", "postgres"),
-		DBPassword:   getEnv("DB_PASSWORD", ""),
-		DatabaseURL:  getEnv("DATABASE_URL", ""),
-
-		RedisURL:     getEnv("REDIS_URL", ""),
-		KafkaBroker:  getEnv("KAFKA_BROKER", ""),
-		NodeEnv:      getEnv("NODE_ENV", "development"),
+		Port:        getEnv("PORT", "3001"),
+		DBHost:      getEnv("DB_HOST", "localhost"),
+		DBPort:      getEnv("DB_PORT", "5432"),
+		DBName:      getEnv("DB_NAME", "four_in_a_row"),
+		DBUser:      getEnv("DB_USER", "postgres"),
+		DBPassword:  getEnv("DB_PASSWORD", ""),
+		DatabaseURL: getEnv("DATABASE_URL", ""),
+
+		StorageDriver: getEnv("STORAGE_DRIVER", "postgres"),
+		SQLitePath:    getEnv("SQLITE_PATH", "data/four_in_a_row.db"),
+
+		RedisURL:    getEnv("REDIS_URL", ""),
+		KafkaBroker: getEnv("KAFKA_BROKER", ""),
+		NodeEnv:     getEnv("NODE_ENV", "development"),
+
+		BotTableSizeMB:   getEnvInt("BOT_TABLE_SIZE_MB", 4),
+		BotMemoryLimitMB: getEnvInt("BOT_MEMORY_LIMIT_MB", 512),
+		OpeningBookPath:  getEnv("OPENING_BOOK_PATH", "data/opening-book.bin"),
+		BotThinkTime:     getEnv("BOT_THINK_TIME", ""),
+		BotMoveDelay:     getEnv("BOT_MOVE_DELAY", ""),
+
+		MatchmakingBotTimeout:   getEnvInt("MATCHMAKING_BOT_TIMEOUT", 10),
+		MatchmakingWindow:       getEnvInt("MATCHMAKING_WINDOW", 100),
+		MatchmakingWindowGrowth: getEnvInt("MATCHMAKING_WINDOW_GROWTH", 50),
+		MatchmakingMaxWindow:    getEnvInt("MATCHMAKING_MAX_WINDOW", 600),
+
+		RoomExpiryMinutes: getEnvInt("ROOM_EXPIRY_MINUTES", 10),
+
+		TimeControl: getEnv("TIME_CONTROL", "none"),
+
+		EventLogSize: getEnvInt("EVENT_LOG_SIZE", 100),
+
+		PingInterval:   getEnvInt("WS_PING_INTERVAL", 15),
+		PongTimeout:    getEnvInt("WS_PONG_TIMEOUT", 10),
+		MaxMessageSize: getEnvInt("WS_MAX_MESSAGE_SIZE", 4096),
+
+		SessionSecret: getEnv("SESSION_SECRET", ""),
 	}
 }
 
@@ -43,4 +96,13 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"context"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// gameRetention is how long a finished game stays around for rematches,
+// resyncs and late spectators.
+const gameRetention = 30 * time.Second
+
+// gameActor runs one game on its own goroutine. While the game is being
+// played that goroutine is the only one touching it, its clock, bot, offers
+// and event log; handlers look the actor up under gm.mu, let go of the lock
+// and hand it a command. When the game ends the actor hands it back to the
+// manager and stops, and from then on it is only touched under gm.mu.
+//
+// An actor takes gm.mu to hand its game back, so gm.mu is never held while
+// waiting on one.
+type gameActor struct {
+	gm       *GameManager
+	game     *models.Game
+	commands chan func()
+	stopped  chan struct{}   // closed when the goroutine exits
+	ctx      context.Context // cancelled when the game ends
+	cancel   context.CancelFunc
+
+	conns        [3]*websocket.Conn // by seat, nil while a player is away
+	away         [3]bool            // seats waiting for their player to reconnect
+	bot          *Bot
+	thinking     context.CancelFunc // set while a bot search is running
+	clock        *time.Timer        // fires when the player to move flags
+	drawOffer    int                // seat that offered
+	takeback     int                // seat that asked
+	moveRequests map[string]int     // seat/request ID -> seq after the move
+	events       eventLog
+	over         bool
+
+	ended bool // guarded by gm.mu; set once the game has been handed back
+}
+
+func (gm *GameManager) newGameActor(game *models.Game) *gameActor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &gameActor{
+		gm:           gm,
+		game:         game,
+		commands:     make(chan func()),
+		stopped:      make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		moveRequests: make(map[string]int),
+	}
+}
+
+// start arms the clock, lets the bot open if it moves first and starts the
+// actor. Callers hold gm.mu and have already sent game_started.
+func (a *gameActor) start() {
+	a.scheduleClock()
+	if a.game.IsBot && a.game.CurrentPlayer == botSeat(a.game) {
+		a.scheduleBotMove()
+	}
+	go a.run()
+}
+
+func (a *gameActor) run() {
+	defer close(a.stopped)
+	for !a.over {
+		command := <-a.commands
+		command()
+	}
+}
+
+// do runs fn on the actor's goroutine and waits for it. It reports false
+// without running fn once the game has ended and the actor has stopped.
+func (a *gameActor) do(fn func()) bool {
+	done := make(chan struct{})
+	select {
+	case a.commands <- func() { defer close(done); fn() }:
+	case <-a.stopped:
+		return false
+	}
+	<-done
+	return true
+}
+
+// inGame runs fn with the game to itself: on the actor while the game is
+// being played, under gm.mu once it is over. fn checks the game's status
+// itself and must not take gm.mu. Callers don't hold gm.mu.
+func (gm *GameManager) inGame(a *gameActor, fn func()) {
+	if a.do(fn) {
+		return
+	}
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	fn()
+}
+
+// gameEnded takes a finished game back from its actor: ratings move, the
+// bot is freed and the game is kept for gameRetention before it goes.
+func (gm *GameManager) gameEnded(a *gameActor) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	a.ended = true
+	delete(gm.bots, a.game.ID)
+	gm.applyResults(a.game)
+
+	// Review moves in the background
+	gm.queueReview(a.game)
+
+	time.AfterFunc(gameRetention, func() { gm.removeGame(a.game.ID) })
+}
+
+func (gm *GameManager) removeGame(gameID string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	delete(gm.games, gameID)
+	gm.spectators.removeGame(gameID)
+}
+
+// username is who plays seat.
+func (a *gameActor) username(seat int) string {
+	if seat == 2 {
+		return a.game.Player2.Username
+	}
+	return a.game.Player1.Username
+}
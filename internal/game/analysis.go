@@ -0,0 +1,129 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Analysis describes a position from the point of view of the side to move.
+// Exact analyses carry solver scores (see Solver), otherwise ColumnScores
+// are heuristic search scores and Outcome may be "unknown".
+type Analysis struct {
+	ColumnScores []*float64 `json:"columnScores"` // nil for full columns
+	BestMove     int        `json:"bestMove"`
+	Outcome      string     `json:"outcome"` // win, loss, draw or unknown
+	Plies        int        `json:"plies,omitempty"`
+	Exact        bool       `json:"exact"`
+	Depth        int        `json:"depth,omitempty"`
+	SideToMove   int        `json:"sideToMove"`
+}
+
+// Analyze scores every column of pos, trying an exact solve first and
+// spending what is left of timeLimit on the heuristic search otherwise.
+// Cancelling ctx stops it with whatever the search had finished.
+func (b *Bot) Analyze(ctx context.Context, pos *Position, timeLimit time.Duration) Analysis {
+	analysis := Analysis{
+		ColumnScores: make([]*float64, BoardWidth),
+		BestMove:     -1,
+		Outcome:      "unknown",
+		SideToMove:   pos.CurrentPlayer(),
+	}
+
+	start := time.Now()
+	if scores, ok := b.ensureSolver().Analyze(ctx, pos, timeLimit*2/3); ok {
+		bestScore := 0
+		for _, col := range columnOrder {
+			score, playable := scores[col]
+			if !playable {
+				continue
+			}
+			value := float64(score)
+			analysis.ColumnScores[col] = &value
+			if analysis.BestMove == -1 || score > bestScore {
+				analysis.BestMove, bestScore = col, score
+			}
+		}
+		value := NewPositionValue(pos, bestScore, analysis.BestMove)
+		analysis.Outcome = value.Outcome
+		analysis.Plies = value.Plies
+		analysis.Exact = true
+		return analysis
+	}
+
+	// Heuristic iterative deepening over every root move
+	b.transTable.NewSearch()
+	b.ctx = ctx
+	b.deadline = start.Add(timeLimit)
+	b.nodes = 0
+	b.aborted = false
+
+	var scores map[int]float64
+	for depth := 1; depth <= boardCells-pos.Moves(); depth++ {
+		result := b.rootScores(pos, depth)
+		if b.aborted {
+			break
+		}
+		scores = result
+		analysis.Depth = depth
+	}
+
+	bestScore := math.Inf(-1)
+	for _, col := range columnOrder {
+		score, playable := scores[col]
+		if !playable {
+			continue
+		}
+		value := score
+		analysis.ColumnScores[col] = &value
+		if score > bestScore {
+			analysis.BestMove, bestScore = col, score
+		}
+	}
+
+	// Forced wins found by the search: the score encodes the cells left empty
+	if bestScore >= winThreshold {
+		analysis.Outcome = "win"
+		analysis.Plies = boardCells - pos.Moves() - int(bestScore-winScore)
+	} else if bestScore <= -winThreshold {
+		analysis.Outcome = "loss"
+		analysis.Plies = boardCells - pos.Moves() - int(-bestScore-winScore)
+	}
+
+	return analysis
+}
+
+// ValidateBoard checks that board is a 6x7 grid that could arise in a game
+// which is still in progress.
+func ValidateBoard(board [][]int) (*Position, error) {
+	if len(board) != BoardHeight {
+		return nil, fmt.Errorf("board must have %d rows", BoardHeight)
+	}
+
+	counts := [3]int{}
+	for row := range board {
+		if len(board[row]) != BoardWidth {
+			return nil, fmt.Errorf("board rows must have %d columns", BoardWidth)
+		}
+		for col, cell := range board[row] {
+			if cell < 0 || cell > 2 {
+				return nil, fmt.Errorf("invalid cell value %d", cell)
+			}
+			counts[cell]++
+			if cell != 0 && row < BoardHeight-1 && board[row+1][col] == 0 {
+				return nil, fmt.Errorf("floating disc in column %d", col)
+			}
+		}
+	}
+
+	if counts[1] != counts[2] && counts[1] != counts[2]+1 {
+		return nil, fmt.Errorf("invalid number of discs per player")
+	}
+
+	pos := PositionFromBoard(board)
+	if pos.HasWon(1) || pos.HasWon(2) || pos.IsFull() {
+		return nil, fmt.Errorf("game is already over")
+	}
+	return pos, nil
+}
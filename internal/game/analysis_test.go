@@ -0,0 +1,28 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeFindsWinOnTheSpot(t *testing.T) {
+	// Player 1 has three in column 0; too early in the game for the solver
+	// to finish, so the heuristic fallback has to see the win
+	for _, limit := range []time.Duration{300 * time.Millisecond, 1500 * time.Millisecond} {
+		bot := NewBotWithDifficulty(DifficultyPerfect, 4)
+		analysis := bot.Analyze(context.Background(), positionAfter(0, 6, 0, 6, 0, 5), limit)
+
+		if analysis.BestMove != 0 {
+			t.Errorf("%v: best move %d, want 0", limit, analysis.BestMove)
+		}
+		if analysis.Outcome != "win" || analysis.Plies != 1 {
+			t.Errorf("%v: outcome %s in %d plies, want win in 1", limit, analysis.Outcome, analysis.Plies)
+		}
+		for col, score := range analysis.ColumnScores {
+			if col != 0 && score != nil && *score >= *analysis.ColumnScores[0] {
+				t.Errorf("%v: column %d scores %.0f, not below the winning column's %.0f", limit, col, *score, *analysis.ColumnScores[0])
+			}
+		}
+	}
+}
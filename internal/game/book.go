@@ -0,0 +1,206 @@
+package game
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+const openingBookMagic = "C4BK"
+
+// OpeningBook holds exact solver scores for every position up to a fixed
+// number of plies, keyed by canonical (mirror-independent) position key.
+type OpeningBook struct {
+	depth  int
+	scores map[uint64]int8
+}
+
+func NewOpeningBook(depth int) *OpeningBook {
+	return &OpeningBook{
+		depth:  depth,
+		scores: make(map[uint64]int8),
+	}
+}
+
+func (ob *OpeningBook) Depth() int {
+	return ob.depth
+}
+
+func (ob *OpeningBook) Len() int {
+	return len(ob.scores)
+}
+
+func (ob *OpeningBook) lookup(pos *Position) (int, bool) {
+	if ob == nil || pos.Moves() > ob.depth {
+		return 0, false
+	}
+	score, ok := ob.scores[pos.canonicalKey()]
+	return int(score), ok
+}
+
+// LoadOpeningBook reads a book written by Save.
+func LoadOpeningBook(path string) (*OpeningBook, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	magic := make([]byte, len(openingBookMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != openingBookMagic {
+		return nil, fmt.Errorf("%s is not an opening book", path)
+	}
+
+	var header struct {
+		Depth uint8
+		Count uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+
+	ob := &OpeningBook{
+		depth:  int(header.Depth),
+		scores: make(map[uint64]int8, header.Count),
+	}
+	for i := uint32(0); i < header.Count; i++ {
+		var entry struct {
+			Key   uint64
+			Score int8
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry); err != nil {
+			return nil, fmt.Errorf("truncated opening book: %w", err)
+		}
+		ob.scores[entry.Key] = entry.Score
+	}
+
+	return ob, nil
+}
+
+func (ob *OpeningBook) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	keys := make([]uint64, 0, len(ob.scores))
+	for key := range ob.scores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	w := bufio.NewWriter(file)
+	w.WriteString(openingBookMagic)
+	binary.Write(w, binary.LittleEndian, uint8(ob.depth))
+	binary.Write(w, binary.LittleEndian, uint32(len(keys)))
+	for _, key := range keys {
+		binary.Write(w, binary.LittleEndian, key)
+		binary.Write(w, binary.LittleEndian, ob.scores[key])
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// BuildOpeningBook solves every distinct position reachable in exactly depth
+// plies and backs the scores up to the starting position. progress, when
+// not nil, is called after each solved position.
+func BuildOpeningBook(depth, workers, tableSizeMB int, progress func(done, total int)) *OpeningBook {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Collect distinct positions per ply
+	levels := make([]map[uint64]*Position, depth+1)
+	for ply := range levels {
+		levels[ply] = make(map[uint64]*Position)
+	}
+	var walk func(pos *Position)
+	walk = func(pos *Position) {
+		key := pos.canonicalKey()
+		if _, seen := levels[pos.Moves()][key]; seen {
+			return
+		}
+		levels[pos.Moves()][key] = pos.Clone()
+		if pos.Moves() == depth {
+			return
+		}
+		for col := 0; col < BoardWidth; col++ {
+			if pos.CanPlay(col) && !pos.IsWinningMove(col) {
+				pos.Play(col)
+				walk(pos)
+				pos.Undo()
+			}
+		}
+	}
+	walk(NewPosition())
+
+	ob := NewOpeningBook(depth)
+
+	// Solve the deepest level in parallel
+	leaves := make([]*Position, 0, len(levels[depth]))
+	for _, pos := range levels[depth] {
+		leaves = append(leaves, pos)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan *Position)
+	done := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			solver := NewSolver(tableSizeMB, nil)
+			for pos := range jobs {
+				score, _ := solver.Solve(context.Background(), pos, 0)
+
+				mu.Lock()
+				ob.scores[pos.canonicalKey()] = int8(score)
+				done++
+				if progress != nil {
+					progress(done, len(leaves))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, pos := range leaves {
+		jobs <- pos
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Back the exact scores up towards the root
+	for ply := depth - 1; ply >= 0; ply-- {
+		for key, pos := range levels[ply] {
+			best := -boardCells
+			for col := 0; col < BoardWidth; col++ {
+				if !pos.CanPlay(col) {
+					continue
+				}
+				score := (boardCells + 1 - pos.Moves()) / 2
+				if !pos.IsWinningMove(col) {
+					pos.Play(col)
+					score = -int(ob.scores[pos.canonicalKey()])
+					pos.Undo()
+				}
+				if score > best {
+					best = score
+				}
+			}
+			ob.scores[key] = int8(best)
+		}
+	}
+
+	return ob
+}
@@ -1,173 +1,254 @@
 package game
 
 import (
+	"context"
 	"emitrr-4-in-a-row/internal/models"
 	"math"
+	"math/bits"
 	"math/rand"
 	"sort"
 	"time"
 )
 
+const (
+	winScore     = 100000.0 // plus the number of empty cells left, so faster wins score higher
+	winThreshold = 10000.0
+
+	minFallbackTime = 500 * time.Millisecond
+	minThinkTime    = 20 * time.Millisecond
+)
+
 type Bot struct {
-	ID       string
-	Username string
-	IsBot    bool
-	transTable map[uint64]TransEntry
+	ID         string
+	Username   string
+	IsBot      bool
+	Difficulty Difficulty
+	settings   DifficultySettings
+	transTable *TransTable
+	solver     *Solver
+	tableSize  int
+
+	// per-search state, a Bot is only ever searched by one goroutine
+	ctx      context.Context
+	nodes    uint64
+	deadline time.Time
+	aborted  bool
 }
 
-type TransEntry struct {
-	Score float64
-	Depth int
-	Flag  int // 0=exact, 1=lower, 2=upper
+func NewBot() *Bot {
+	return NewBotWithDifficulty(DefaultDifficulty, DefaultTransTableSizeMB)
 }
 
-func NewBot() *Bot {
-	return &Bot{
+// NewBotWithDifficulty creates an engine with its own transposition table of
+// at most tableSizeMB megabytes. Bots are not shared between games.
+func NewBotWithDifficulty(difficulty Difficulty, tableSizeMB int) *Bot {
+	bot := &Bot{
 		ID:         "bot",
 		Username:   "AI Bot",
 		IsBot:      true,
-		transTable: make(map[uint64]TransEntry),
+		Difficulty: difficulty,
+		settings:   difficulty.Settings(),
+		transTable: NewTransTable(tableSizeMB),
+		tableSize:  tableSizeMB,
+	}
+	if bot.settings.UseSolver {
+		bot.solver = NewSolver(tableSizeMB, nil)
+	}
+	return bot
+}
+
+// UseOpeningBook lets the exact solver answer opening positions from book.
+func (b *Bot) UseOpeningBook(book *OpeningBook) {
+	b.ensureSolver().book = book
+}
+
+func (b *Bot) TableStats() TransTableStats {
+	stats := b.transTable.Stats()
+	if b.solver != nil {
+		stats = stats.Add(b.solver.TableStats())
+	}
+	return stats
+}
+
+// PositionValue reports the game-theoretic value of board for the side to move.
+func (b *Bot) PositionValue(ctx context.Context, board [][]int, timeLimit time.Duration) (PositionValue, error) {
+	return b.ensureSolver().Value(ctx, PositionFromBoard(board), timeLimit)
+}
+
+func (b *Bot) ensureSolver() *Solver {
+	if b.solver == nil {
+		b.solver = NewSolver(b.tableSize, nil)
 	}
+	return b.solver
 }
 
-func (b *Bot) GetBestMove(game *models.Game) int {
-	validMoves := b.getValidMoves(game.Board)
+// GetBestMove picks the bot's move in pos, thinking for at most timeLimit.
+// The search stops early when ctx is cancelled; the move it returns then is
+// only a guess.
+func (b *Bot) GetBestMove(ctx context.Context, pos *Position, timeLimit time.Duration) int {
+	validMoves := pos.ValidMoves()
 	if len(validMoves) == 0 {
 		return -1
 	}
 
-	// Immediate tactical moves (win/block)
-	if move := b.GetImmediateMove(game); move != nil {
-		return *move
+	// Deliberate blunders for the easier levels
+	if b.settings.BlunderRate > 0 && rand.Float64() < b.settings.BlunderRate {
+		return validMoves[rand.Intn(len(validMoves))]
 	}
 
-	// Advanced threat analysis
-	if move := b.analyzeThreats(game.Board); move != -1 {
+	// Immediate tactical moves (win/block)
+	if move := b.immediateMove(pos); move != -1 {
 		return move
 	}
 
-	// Iterative deepening with time limit
-	depth := b.getOptimalDepth(game.Board)
-	result := b.iterativeDeepening(game.Board, depth, 2*time.Second)
-	
-	for _, col := range validMoves {
-		if result.Column == col {
-			return result.Column
+	// Exact solve, falling back to the heuristic search when it runs out of time
+	if b.settings.UseSolver && timeLimit > minFallbackTime {
+		start := time.Now()
+		if col, _, ok := b.solver.BestMove(ctx, pos, timeLimit-minFallbackTime); ok {
+			return col
+		}
+		timeLimit -= time.Since(start)
+		if timeLimit < minFallbackTime {
+			timeLimit = minFallbackTime
 		}
 	}
-	
-	return b.selectStrategicMove(game.Board, validMoves)
+
+	// Iterative deepening with time limit
+	depth := b.getOptimalDepth(pos)
+	if b.settings.MaxDepth > 0 && b.settings.MaxDepth < depth {
+		depth = b.settings.MaxDepth
+	}
+	b.transTable.NewSearch()
+	result := b.iterativeDeepening(ctx, pos, depth, timeLimit)
+
+	if pos.CanPlay(result.Column) {
+		return result.Column
+	}
+
+	return b.selectStrategicMove(validMoves)
 }
 
-func (b *Bot) getOptimalDepth(board [][]int) int {
-	emptySpaces := 0
-	for i := 0; i < 6; i++ {
-		for j := 0; j < 7; j++ {
-			if board[i][j] == 0 {
-				emptySpaces++
-			}
-		}
+// thinkTime is the difficulty's time limit, cut down when the bot is on a
+// clock so one move never takes more than a tenth of what it has left.
+func (b *Bot) thinkTime(game *models.Game) time.Duration {
+	limit := max(b.settings.TimeLimit, minThinkTime)
+	if game.Clock == nil {
+		return limit
 	}
-	
+	if budget := game.RemainingTime(game.CurrentPlayer, time.Now()) / 10; budget < limit {
+		limit = max(budget, minThinkTime)
+	}
+	return limit
+}
+
+func (b *Bot) getOptimalDepth(pos *Position) int {
+	emptySpaces := boardCells - pos.Moves()
+
 	if emptySpaces > 35 {
-		return 8
-	} else if emptySpaces > 20 {
-		return 10
-	} else if emptySpaces > 10 {
 		return 12
+	} else if emptySpaces > 20 {
+		return 16
 	} else {
-		return 15 // Endgame - solve completely
+		return emptySpaces // Endgame - solve completely
 	}
 }
 
-func (b *Bot) iterativeDeepening(board [][]int, maxDepth int, timeLimit time.Duration) MinimaxResult {
+func (b *Bot) iterativeDeepening(ctx context.Context, pos *Position, maxDepth int, timeLimit time.Duration) MinimaxResult {
 	start := time.Now()
-	var bestResult MinimaxResult
-	
+	b.ctx = ctx
+	b.deadline = start.Add(timeLimit)
+	b.nodes = 0
+	b.aborted = false
+	bestResult := MinimaxResult{Column: -1}
+
 	for depth := 1; depth <= maxDepth; depth++ {
-		if time.Since(start) > timeLimit {
+		var result MinimaxResult
+		if b.settings.EvalNoise > 0 {
+			result = b.noisyRootSearch(pos, depth)
+		} else {
+			result = b.searchRoot(pos, depth)
+		}
+
+		// A search cut short by the deadline or ctx is incomplete, keep the last full one
+		if b.aborted {
 			break
 		}
-		
-		result := b.minimax(board, depth, math.Inf(-1), math.Inf(1), true)
 		bestResult = result
-		
+		bestResult.Depth = depth
+
 		// If we found a winning move, return immediately
-		if result.Score >= 10000 {
+		if result.Score >= winThreshold {
 			break
 		}
 	}
-	
+
+	bestResult.Nodes = b.nodes
 	return bestResult
 }
 
-func (b *Bot) analyzeThreats(board [][]int) int {
-	// Look for fork opportunities (multiple threats)
-	bestScore := -1.0
-	bestMove := -1
-	
-	for col := 0; col < 7; col++ {
-		if board[0][col] != 0 {
-			continue
+// searchRoot runs a negamax search from pos and remembers which root move
+// produced the best score.
+func (b *Bot) searchRoot(pos *Position, depth int) MinimaxResult {
+	alpha, beta := math.Inf(-1), math.Inf(1)
+	best := MinimaxResult{Score: math.Inf(-1), Column: -1}
+
+	for _, col := range b.orderMoves(pos) {
+		pos.Play(col)
+		score := -b.negamax(pos, depth-1, -beta, -alpha)
+		pos.Undo()
+
+		if b.aborted {
+			break
 		}
-		
-		testBoard := b.makeMove(board, col, 2)
-		threats := b.countAdvancedThreats(testBoard, 2)
-		defensiveValue := b.evaluateDefensivePosition(testBoard, col)
-		
-		score := float64(threats)*100 + defensiveValue
-		
-		if score > bestScore {
-			bestScore = score
-			bestMove = col
+		if score > best.Score {
+			best = MinimaxResult{Score: score, Column: col}
 		}
+		alpha = math.Max(alpha, score)
 	}
-	
-	if bestScore > 150 { // Threshold for strong tactical move
-		return bestMove
-	}
-	return -1
+	return best
 }
 
-func (b *Bot) countAdvancedThreats(board [][]int, player int) int {
-	threats := 0
-	for col := 0; col < 7; col++ {
-		if board[0][col] == 0 {
-			testBoard := b.makeMove(board, col, player)
-			if b.checkWinInBoard(testBoard, col, player) {
-				threats++
-			}
+// noisyRootSearch scores every root move with a full window and perturbs the
+// scores by up to EvalNoise, so weaker levels prefer "good enough" moves.
+func (b *Bot) noisyRootSearch(pos *Position, depth int) MinimaxResult {
+	best := MinimaxResult{Score: math.Inf(-1), Column: -1}
+	for col, score := range b.rootScores(pos, depth) {
+		score += (rand.Float64()*2 - 1) * b.settings.EvalNoise
+
+		if score > best.Score {
+			best = MinimaxResult{Score: score, Column: col}
 		}
 	}
-	return threats
+	return best
 }
 
-func (b *Bot) evaluateDefensivePosition(board [][]int, col int) float64 {
-	score := 0.0
-	
-	// Check if this move blocks opponent threats
-	testBoard := b.makeMove(board, col, 1) // Simulate opponent move
-	if b.checkWinInBoard(testBoard, col, 1) {
-		score += 200 // High value for blocking
-	}
-	
-	// Check for trap setups (moves that create unavoidable threats)
-	for nextCol := 0; nextCol < 7; nextCol++ {
-		if board[0][nextCol] == 0 && nextCol != col {
-			nextBoard := b.makeMove(board, nextCol, 2)
-			if b.countAdvancedThreats(nextBoard, 2) >= 2 {
-				score += 50
-			}
+// rootScores returns the full-window negamax score of every playable column.
+// The result is incomplete if the search was aborted.
+func (b *Bot) rootScores(pos *Position, depth int) map[int]float64 {
+	scores := make(map[int]float64, BoardWidth)
+	for _, col := range pos.ValidMoves() {
+		// negamax only sees threats for the side to move, not a four just made
+		if pos.IsWinningMove(col) {
+			scores[col] = winScore + float64(boardCells-pos.Moves()-1)
+			continue
 		}
+
+		pos.Play(col)
+		score := -b.negamax(pos, depth-1, math.Inf(-1), math.Inf(1))
+		pos.Undo()
+
+		if b.aborted {
+			break
+		}
+		scores[col] = score
 	}
-	
-	return score
+	return scores
 }
 
-func (b *Bot) selectStrategicMove(board [][]int, validMoves []int) int {
+func (b *Bot) selectStrategicMove(validMoves []int) int {
 	// Prioritize center columns with some randomness
 	centerPreference := []int{3, 2, 4, 1, 5, 0, 6}
-	
+
 	for _, col := range centerPreference {
 		for _, valid := range validMoves {
 			if col == valid {
@@ -178,480 +259,248 @@ func (b *Bot) selectStrategicMove(board [][]int, validMoves []int) int {
 			}
 		}
 	}
-	
+
 	return validMoves[rand.Intn(len(validMoves))]
 }
 
 type MinimaxResult struct {
 	Score  float64
 	Column int
+	Depth  int
+	Nodes  uint64
 }
 
-func (b *Bot) minimax(board [][]int, depth int, alpha, beta float64, isMaximizing bool) MinimaxResult {
-	// Transposition table lookup
-	hash := b.hashBoard(board)
-	if entry, exists := b.transTable[hash]; exists && entry.Depth >= depth {
-		if entry.Flag == 0 || (entry.Flag == 1 && entry.Score >= beta) || (entry.Flag == 2 && entry.Score <= alpha) {
-			return MinimaxResult{Score: entry.Score, Column: -1}
-		}
+// negamax returns the score of pos from the point of view of the side to move.
+func (b *Bot) negamax(pos *Position, depth int, alpha, beta float64) float64 {
+	b.nodes++
+	if b.nodes&1023 == 0 && b.expired() {
+		b.aborted = true
 	}
-
-	score := b.evaluateBoard(board)
-	if depth == 0 || math.Abs(score) >= 10000 || b.isBoardFull(board) {
-		return MinimaxResult{Score: score, Column: -1}
+	if b.aborted {
+		return 0
 	}
 
-	validMoves := b.getValidMoves(board)
-	if len(validMoves) == 0 {
-		return MinimaxResult{Score: score, Column: -1}
+	if pos.IsFull() {
+		return 0
 	}
 
-	// Move ordering for better pruning
-	orderedMoves := b.orderMoves(board, validMoves, isMaximizing)
-	bestColumn := orderedMoves[0]
-	originalAlpha := alpha
-
-	if isMaximizing {
-		maxScore := math.Inf(-1)
-		for _, col := range orderedMoves {
-			newBoard := b.makeMove(board, col, 2)
-			result := b.minimax(newBoard, depth-1, alpha, beta, false)
-
-			if result.Score > maxScore {
-				maxScore = result.Score
-				bestColumn = col
-			}
-
-			alpha = math.Max(alpha, result.Score)
-			if beta <= alpha {
-				break // Beta cutoff
-			}
-		}
-
-		// Store in transposition table
-		flag := 0
-		if maxScore <= originalAlpha {
-			flag = 2 // Upper bound
-		} else if maxScore >= beta {
-			flag = 1 // Lower bound
-		}
-		b.transTable[hash] = TransEntry{Score: maxScore, Depth: depth, Flag: flag}
-
-		return MinimaxResult{Score: maxScore, Column: bestColumn}
-	} else {
-		minScore := math.Inf(1)
-		for _, col := range orderedMoves {
-			newBoard := b.makeMove(board, col, 1)
-			result := b.minimax(newBoard, depth-1, alpha, beta, true)
-
-			if result.Score < minScore {
-				minScore = result.Score
-				bestColumn = col
-			}
-
-			beta = math.Min(beta, result.Score)
-			if beta <= alpha {
-				break // Alpha cutoff
-			}
-		}
-
-		// Store in transposition table
-		flag := 0
-		if minScore <= originalAlpha {
-			flag = 2
-		} else if minScore >= beta {
-			flag = 1
-		}
-		b.transTable[hash] = TransEntry{Score: minScore, Depth: depth, Flag: flag}
+	// Win on the spot
+	if pos.threats(pos.current()) != 0 {
+		return winScore + float64(boardCells-pos.Moves()-1)
+	}
 
-		return MinimaxResult{Score: minScore, Column: bestColumn}
+	if depth == 0 {
+		return b.evaluate(pos)
 	}
-}
 
-func (b *Bot) hashBoard(board [][]int) uint64 {
-	var hash uint64 = 0
-	for i := 0; i < 6; i++ {
-		for j := 0; j < 7; j++ {
-			hash = hash*3 + uint64(board[i][j])
+	// Transposition table lookup
+	key := pos.Key()
+	if entry, exists := b.transTable.Get(key); exists && entry.Depth >= depth {
+		if entry.Flag == 0 || (entry.Flag == 1 && entry.Score >= beta) || (entry.Flag == 2 && entry.Score <= alpha) {
+			return entry.Score
 		}
 	}
-	return hash
-}
 
-func (b *Bot) orderMoves(board [][]int, moves []int, isMaximizing bool) []int {
-	type moveScore struct {
-		col   int
-		score float64
-	}
-	
-	scores := make([]moveScore, len(moves))
-	for i, col := range moves {
-		player := 2
-		if !isMaximizing {
-			player = 1
-		}
-		testBoard := b.makeMove(board, col, player)
-		score := b.evaluateBoard(testBoard)
-		
-		// Prioritize center columns
-		if col == 3 {
-			score += 10
-		} else if col == 2 || col == 4 {
-			score += 5
+	originalAlpha := alpha
+	bestScore := math.Inf(-1)
+	for _, col := range b.orderMoves(pos) {
+		pos.Play(col)
+		score := -b.negamax(pos, depth-1, -beta, -alpha)
+		pos.Undo()
+
+		if score > bestScore {
+			bestScore = score
 		}
-		
-		scores[i] = moveScore{col: col, score: score}
-	}
-	
-	// Sort by score (descending for maximizing, ascending for minimizing)
-	sort.Slice(scores, func(i, j int) bool {
-		if isMaximizing {
-			return scores[i].score > scores[j].score
+		alpha = math.Max(alpha, score)
+		if alpha >= beta {
+			break // Cutoff
 		}
-		return scores[i].score < scores[j].score
-	})
-	
-	orderedMoves := make([]int, len(moves))
-	for i, ms := range scores {
-		orderedMoves[i] = ms.col
 	}
-	return orderedMoves
-}
-
-func (b *Bot) evaluateBoard(board [][]int) float64 {
-	score := 0.0
 
-	// Check for immediate wins/losses
-	if winner := b.checkBoardWinner(board); winner != 0 {
-		if winner == 2 {
-			return 100000
-		}
-		return -100000
+	if b.aborted {
+		return 0
 	}
 
-	// Advanced positional evaluation
-	score += b.evaluatePositionalAdvantage(board)
-	score += b.evaluateConnections(board, 2) - b.evaluateConnections(board, 1)
-	score += b.evaluateThreatPotential(board)
-	score += b.evaluateControlledColumns(board)
+	// Store in transposition table
+	flag := 0
+	if bestScore <= originalAlpha {
+		flag = 2 // Upper bound
+	} else if bestScore >= beta {
+		flag = 1 // Lower bound
+	}
+	b.transTable.Put(key, TransEntry{Score: bestScore, Depth: depth, Flag: flag})
 
-	return score
+	return bestScore
 }
 
-func (b *Bot) evaluatePositionalAdvantage(board [][]int) float64 {
-	score := 0.0
-	
-	// Center control is crucial
-	for row := 0; row < 6; row++ {
-		if board[row][3] == 2 {
-			score += 8.0 * float64(6-row) // Higher pieces worth more
-		} else if board[row][3] == 1 {
-			score -= 8.0 * float64(6-row)
-		}
-	}
-	
-	// Adjacent center columns
-	for row := 0; row < 6; row++ {
-		for _, col := range []int{2, 4} {
-			if board[row][col] == 2 {
-				score += 5.0 * float64(6-row)
-			} else if board[row][col] == 1 {
-				score -= 5.0 * float64(6-row)
-			}
-		}
-	}
-	
-	// Penalize edge columns
-	for row := 0; row < 6; row++ {
-		for _, col := range []int{0, 6} {
-			if board[row][col] == 2 {
-				score -= 3.0
-			} else if board[row][col] == 1 {
-				score += 1.0 // Slightly good to force opponent to edges
-			}
-		}
-	}
-	
-	return score
+// expired reports whether the running search is out of time or cancelled.
+func (b *Bot) expired() bool {
+	return time.Now().After(b.deadline) || b.ctx.Err() != nil
 }
 
-func (b *Bot) evaluateConnections(board [][]int, player int) float64 {
-	score := 0.0
-	
-	// Horizontal connections
-	for row := 0; row < 6; row++ {
-		for col := 0; col < 4; col++ {
-			window := []int{board[row][col], board[row][col+1], board[row][col+2], board[row][col+3]}
-			score += b.scoreAdvancedWindow(window, player, "horizontal")
-		}
-	}
-	
-	// Vertical connections
-	for col := 0; col < 7; col++ {
-		for row := 0; row < 3; row++ {
-			window := []int{board[row][col], board[row+1][col], board[row+2][col], board[row+3][col]}
-			score += b.scoreAdvancedWindow(window, player, "vertical")
-		}
-	}
-	
-	// Diagonal connections
-	for row := 0; row < 3; row++ {
-		for col := 0; col < 4; col++ {
-			window1 := []int{board[row][col], board[row+1][col+1], board[row+2][col+2], board[row+3][col+3]}
-			score += b.scoreAdvancedWindow(window1, player, "diagonal")
-		}
-		for col := 3; col < 7; col++ {
-			window2 := []int{board[row][col], board[row+1][col-1], board[row+2][col-2], board[row+3][col-3]}
-			score += b.scoreAdvancedWindow(window2, player, "diagonal")
-		}
-	}
-	
-	return score
-}
+var columnOrder = [BoardWidth]int{3, 2, 4, 1, 5, 0, 6}
 
-func (b *Bot) scoreAdvancedWindow(window []int, player int, direction string) float64 {
-	score := 0.0
-	opponent := 3 - player
-	
-	playerCount := 0
-	opponentCount := 0
-	emptyCount := 0
-	
-	for _, cell := range window {
-		if cell == player {
-			playerCount++
-		} else if cell == opponent {
-			opponentCount++
-		} else {
-			emptyCount++
-		}
-	}
-	
-	// Can't form 4 in a row if opponent has pieces
-	if opponentCount > 0 {
-		return 0
+// orderMoves sorts the playable columns by how many immediate threats the
+// move creates, breaking ties by closeness to the center.
+func (b *Bot) orderMoves(pos *Position) []int {
+	type moveScore struct {
+		col   int
+		score int
 	}
-	
-	// Scoring based on potential
-	switch playerCount {
-	case 4:
-		score = 10000
-	case 3:
-		score = 500
-		if direction == "vertical" {
-			score *= 1.5 // Vertical threats are stronger
-		}
-	case 2:
-		score = 50
-		if direction == "horizontal" && emptyCount == 2 {
-			score *= 1.2 // Open-ended horizontals are valuable
+
+	me := pos.current()
+	mask := pos.mask()
+	scores := make([]moveScore, 0, BoardWidth)
+	for _, col := range columnOrder {
+		if !pos.CanPlay(col) {
+			continue
 		}
-	case 1:
-		score = 5
+		move := uint64(1) << pos.height[col]
+		threats := popcount(winningCells(me|move, mask|move))
+		scores = append(scores, moveScore{col: col, score: threats})
 	}
-	
-	return score
-}
 
-func (b *Bot) evaluateThreatPotential(board [][]int) float64 {
-	score := 0.0
-	
-	// Count potential threats for both players
-	botThreats := b.countPotentialThreats(board, 2)
-	oppThreats := b.countPotentialThreats(board, 1)
-	
-	score += float64(botThreats)*20 - float64(oppThreats)*25
-	
-	return score
-}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
 
-func (b *Bot) countPotentialThreats(board [][]int, player int) int {
-	threats := 0
-	for col := 0; col < 7; col++ {
-		if board[0][col] == 0 {
-			testBoard := b.makeMove(board, col, player)
-			if b.checkWinInBoard(testBoard, col, player) {
-				threats++
-			}
-		}
+	orderedMoves := make([]int, len(scores))
+	for i, ms := range scores {
+		orderedMoves[i] = ms.col
 	}
-	return threats
+	return orderedMoves
 }
 
-func (b *Bot) evaluateControlledColumns(board [][]int) float64 {
-	score := 0.0
-	
-	for col := 0; col < 7; col++ {
-		botControl := 0
-		oppControl := 0
-		
-		for row := 5; row >= 0; row-- {
-			if board[row][col] == 2 {
-				botControl++
-			} else if board[row][col] == 1 {
-				oppControl++
-			} else {
-				break // Empty space, stop counting
-			}
-		}
-		
-		if botControl > oppControl {
-			score += float64(botControl-oppControl) * 3
-		} else if oppControl > botControl {
-			score -= float64(oppControl-botControl) * 3
-		}
-	}
-	
-	return score
+type evalWindow struct {
+	mask     uint64
+	vertical bool
 }
 
-func (b *Bot) checkBoardWinner(board [][]int) int {
-	for row := 0; row < 6; row++ {
-		for col := 0; col < 7; col++ {
-			if board[row][col] != 0 {
-				if b.checkWinFromPosition(board, row, col, board[row][col]) {
-					return board[row][col]
+var (
+	evalWindows = func() []evalWindow {
+		var windows []evalWindow
+		directions := [][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}} // (dcol, drow)
+		for _, dir := range directions {
+			for col := 0; col < BoardWidth; col++ {
+				for row := 0; row < BoardHeight; row++ {
+					endCol, endRow := col+3*dir[0], row+3*dir[1]
+					if endCol >= BoardWidth || endRow < 0 || endRow >= BoardHeight {
+						continue
+					}
+					var mask uint64
+					for i := 0; i < 4; i++ {
+						mask |= 1 << ((col+i*dir[0])*columnBits + row + i*dir[1])
+					}
+					windows = append(windows, evalWindow{mask: mask, vertical: dir[0] == 0})
 				}
 			}
 		}
-	}
-	return 0
-}
+		return windows
+	}()
 
-func (b *Bot) checkWinFromPosition(board [][]int, row, col, player int) bool {
-	directions := [][]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
-	
-	for _, dir := range directions {
-		count := 1
-		dr, dc := dir[0], dir[1]
-		
-		// Check positive direction
-		for i := 1; i < 4; i++ {
-			nr, nc := row+dr*i, col+dc*i
-			if nr >= 0 && nr < 6 && nc >= 0 && nc < 7 && board[nr][nc] == player {
-				count++
-			} else {
-				break
-			}
-		}
-		
-		// Check negative direction
-		for i := 1; i < 4; i++ {
-			nr, nc := row-dr*i, col-dc*i
-			if nr >= 0 && nr < 6 && nc >= 0 && nc < 7 && board[nr][nc] == player {
-				count++
-			} else {
-				break
-			}
+	// Center control: higher pieces worth more
+	positionalWeights = func() [BoardWidth * columnBits]float64 {
+		var weights [BoardWidth * columnBits]float64
+		for row := 0; row < BoardHeight; row++ {
+			weights[3*columnBits+row] = 8.0 * float64(row+1)
+			weights[2*columnBits+row] = 5.0 * float64(row+1)
+			weights[4*columnBits+row] = 5.0 * float64(row+1)
 		}
-		
-		if count >= 4 {
-			return true
-		}
-	}
-	return false
-}
-
+		return weights
+	}()
+	edgeMask = columnMask(0) | columnMask(BoardWidth-1)
+)
 
+// evaluate scores a position without a winner from the side to move's view.
+func (b *Bot) evaluate(pos *Position) float64 {
+	me, opp := pos.current(), pos.opponent()
+	score := 0.0
 
-func (b *Bot) getValidMoves(board [][]int) []int {
-	var validMoves []int
-	for col := 0; col < 7; col++ {
-		if board[0][col] == 0 {
-			validMoves = append(validMoves, col)
+	// Connection patterns (2, 3 in a row with room to complete)
+	for _, w := range evalWindows {
+		mine, theirs := popcount(me&w.mask), popcount(opp&w.mask)
+		if theirs == 0 {
+			score += scoreWindow(mine, w.vertical)
+		} else if mine == 0 {
+			score -= scoreWindow(theirs, w.vertical)
 		}
 	}
-	return validMoves
-}
 
-func (b *Bot) makeMove(board [][]int, column, player int) [][]int {
-	newBoard := make([][]int, 6)
-	for i := range board {
-		newBoard[i] = make([]int, 7)
-		copy(newBoard[i], board[i])
+	// Positional advantage
+	for stones := me; stones != 0; stones &= stones - 1 {
+		score += positionalWeights[bits.TrailingZeros64(stones)]
 	}
-
-	for row := 5; row >= 0; row-- {
-		if newBoard[row][column] == 0 {
-			newBoard[row][column] = player
-			break
-		}
+	for stones := opp; stones != 0; stones &= stones - 1 {
+		score -= positionalWeights[bits.TrailingZeros64(stones)]
 	}
+	score -= 3.0 * float64(popcount(me&edgeMask))
+	score += 1.0 * float64(popcount(opp&edgeMask)) // Slightly good to force opponent to edges
 
-	return newBoard
+	// Threat potential
+	score += float64(popcount(pos.threats(me)))*20 - float64(popcount(pos.threats(opp)))*25
+
+	// Column control
+	score += float64(popcount(me)-popcount(opp)) * 3
+
+	return score
 }
 
-func (b *Bot) isBoardFull(board [][]int) bool {
-	for _, cell := range board[0] {
-		if cell == 0 {
-			return false
+func scoreWindow(count int, vertical bool) float64 {
+	switch count {
+	case 3:
+		if vertical {
+			return 750 // Vertical threats are stronger
+		}
+		return 500
+	case 2:
+		if !vertical {
+			return 60 // Open two on a line
 		}
+		return 50
+	case 1:
+		return 5
 	}
-	return true
+	return 0
 }
 
-func (b *Bot) GetImmediateMove(game *models.Game) *int {
-	board := game.Board
+// immediateMove returns a column that wins at once, blocks the opponent's
+// win or creates two threats at once, or -1 if there is none.
+func (b *Bot) immediateMove(pos *Position) int {
+	me, opp := pos.current(), pos.opponent()
 
 	// 1. Immediate win
-	for col := 0; col < 7; col++ {
-		if board[0][col] == 0 {
-			testBoard := b.makeMove(board, col, 2)
-			if b.checkWinInBoard(testBoard, col, 2) {
-				return &col
-			}
-		}
+	if wins := pos.threats(me); wins != 0 {
+		return bits.TrailingZeros64(wins) / columnBits
 	}
 
 	// 2. Block opponent win
-	for col := 0; col < 7; col++ {
-		if board[0][col] == 0 {
-			testBoard := b.makeMove(board, col, 1)
-			if b.checkWinInBoard(testBoard, col, 1) {
-				return &col
-			}
-		}
+	if blocks := pos.threats(opp); blocks != 0 {
+		return bits.TrailingZeros64(blocks) / columnBits
 	}
 
 	// 3. Create multiple threats
-	for col := 0; col < 7; col++ {
-		if board[0][col] == 0 {
-			testBoard := b.makeMove(board, col, 2)
-			threats := b.countThreats(testBoard, 2)
-			if threats >= 2 {
-				return &col
-			}
+	mask := pos.mask()
+	for _, col := range columnOrder {
+		if !pos.CanPlay(col) {
+			continue
+		}
+		move := uint64(1) << pos.height[col]
+		// never set up a fork directly under an opponent winning cell
+		if winningCells(opp, mask|move)&(move<<1) != 0 {
+			continue
+		}
+		playable := ((mask | move) + bottomMask) & fullBoardMask
+		if popcount(winningCells(me|move, mask|move)&playable) >= 2 {
+			return col
 		}
 	}
 
-	return nil
+	return -1
 }
 
-func (b *Bot) countThreats(board [][]int, player int) int {
-	threats := 0
-	for col := 0; col < 7; col++ {
-		if board[0][col] == 0 {
-			testBoard := b.makeMove(board, col, player)
-			if b.checkWinInBoard(testBoard, col, player) {
-				threats++
-			}
-		}
+func (b *Bot) GetImmediateMove(game *models.Game) *int {
+	if move := b.immediateMove(PositionFromBoard(game.Board)); move != -1 {
+		return &move
 	}
-	return threats
+	return nil
 }
-
-func (b *Bot) checkWinInBoard(board [][]int, col, player int) bool {
-	row := -1
-	for r := 5; r >= 0; r-- {
-		if board[r][col] == player && (r == 5 || board[r+1][col] != 0) {
-			row = r
-			break
-		}
-	}
-
-	if row == -1 {
-		return false
-	}
-
-	return b.checkWinFromPosition(board, row, col, player)
-}
\ No newline at end of file
@@ -0,0 +1,216 @@
+package game
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	clientBufferSize   = 64
+	clientWriteTimeout = 10 * time.Second
+)
+
+// client owns the write side of one WebSocket connection. Messages are queued
+// and written by its own goroutine, so nothing blocks on the network while
+// holding gm.mu and gorilla/websocket never sees two writers at once.
+type client struct {
+	conn    *websocket.Conn
+	send    chan []byte
+	quit    chan struct{} // closed to stop the writer and hang up
+	ping    time.Duration // between heartbeats, 0 for none
+	latency latency
+}
+
+// latency keeps heartbeat round trips, recorded from the read loop.
+type latency struct {
+	mu      sync.Mutex
+	last    time.Duration
+	max     time.Duration
+	total   time.Duration
+	samples int
+}
+
+// clientSet has its own lock so messages can be queued whether or not gm.mu
+// is held.
+type clientSet struct {
+	mu     sync.Mutex
+	byConn map[*websocket.Conn]*client
+}
+
+// Connect starts the writer for a newly upgraded connection. Everything sent
+// to it is queued from then on, until HandlePlayerDisconnect.
+//
+// The writer pings every PingInterval. A connection that hasn't answered
+// within PongTimeout after that fails its next read, so half-open
+// connections go through the usual disconnect and reconnect window instead
+// of lingering until TCP notices. Each pong is reported back as latency.
+func (gm *GameManager) Connect(conn *websocket.Conn) {
+	ping := time.Duration(gm.cfg.PingInterval) * time.Second
+	c := gm.clients.add(conn, ping)
+
+	if gm.cfg.MaxMessageSize > 0 {
+		conn.SetReadLimit(int64(gm.cfg.MaxMessageSize))
+	}
+	if ping <= 0 {
+		return
+	}
+
+	timeout := ping + time.Duration(gm.cfg.PongTimeout)*time.Second
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetPongHandler(func(payload string) error {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+
+		sent, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil
+		}
+		rtt := time.Since(time.Unix(0, sent))
+		c.latency.record(rtt)
+		gm.sendMessage(conn, &protocol.Latency{RTT: int(rtt.Milliseconds())})
+		return nil
+	})
+}
+
+// trackLatency reports a closed connection's heartbeat round trips.
+func (gm *GameManager) trackLatency(conn *websocket.Conn, c *client) {
+	c.latency.mu.Lock()
+	last, worst, samples := c.latency.last, c.latency.max, c.latency.samples
+	var avg time.Duration
+	if samples > 0 {
+		avg = c.latency.total / time.Duration(samples)
+	}
+	c.latency.mu.Unlock()
+
+	if samples == 0 || gm.analyticsService == nil {
+		return
+	}
+
+	gm.mu.RLock()
+	username, gameID := "", ""
+	if player, exists := gm.connections[conn]; exists {
+		username, gameID = player.Username, player.GameID
+	}
+	spectator := gm.spectators.isSpectating(conn)
+	gm.mu.RUnlock()
+
+	gm.analyticsService.TrackEvent("connection_latency", map[string]interface{}{
+		"player":    username,
+		"gameId":    gameID,
+		"spectator": spectator,
+		"samples":   samples,
+		"lastRttMs": last.Milliseconds(),
+		"avgRttMs":  avg.Milliseconds(),
+		"maxRttMs":  worst.Milliseconds(),
+	})
+}
+
+func (l *latency) record(rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.last = rtt
+	l.max = max(l.max, rtt)
+	l.total += rtt
+	l.samples++
+}
+
+func (s *clientSet) add(conn *websocket.Conn, ping time.Duration) *client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &client{
+		conn: conn,
+		send: make(chan []byte, clientBufferSize),
+		quit: make(chan struct{}),
+		ping: ping,
+	}
+	s.byConn[conn] = c
+	go c.writeLoop()
+	return c
+}
+
+func (s *clientSet) remove(conn *websocket.Conn) *client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.byConn[conn]
+	if !ok {
+		return nil
+	}
+	delete(s.byConn, conn)
+	close(c.quit)
+	return c
+}
+
+// finish stops queueing messages for conn and lets its writer flush what is
+// already queued before hanging up.
+func (s *clientSet) finish(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.byConn[conn]; ok {
+		delete(s.byConn, conn)
+		close(c.send)
+	}
+}
+
+// send queues a message and never blocks. A client whose queue is full has
+// fallen too far behind and is hung up on; the read loop then sees the
+// disconnect, and players get the usual window to reconnect and catch up.
+func (s *clientSet) send(conn *websocket.Conn, message []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.byConn[conn]
+	if !ok {
+		return false
+	}
+	select {
+	case c.send <- message:
+		return true
+	default:
+		log.Printf("Dropping slow client %s", conn.RemoteAddr())
+		delete(s.byConn, conn)
+		close(c.quit)
+		return false
+	}
+}
+
+func (c *client) writeLoop() {
+	defer c.conn.Close()
+
+	var heartbeat <-chan time.Time
+	if c.ping > 0 {
+		ticker := time.NewTicker(c.ping)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("Failed to send message to %s: %v", c.conn.RemoteAddr(), err)
+				return
+			}
+		case now := <-heartbeat:
+			payload := []byte(strconv.FormatInt(now.UnixNano(), 10))
+			if err := c.conn.WriteControl(websocket.PingMessage, payload, time.Now().Add(clientWriteTimeout)); err != nil {
+				log.Printf("Failed to ping %s: %v", c.conn.RemoteAddr(), err)
+				return
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
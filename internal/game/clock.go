@@ -0,0 +1,69 @@
+package game
+
+import (
+	"log"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+)
+
+// scheduleClock arms a timer for the moment the player to move runs out of
+// time, replacing any earlier one.
+func (a *gameActor) scheduleClock() {
+	a.stopClock()
+	game := a.game
+	if game.Clock == nil || game.Clock.Paused || game.Status != "playing" {
+		return
+	}
+
+	remaining := game.RemainingTime(game.CurrentPlayer, time.Now())
+	a.clock = time.AfterFunc(remaining, func() {
+		a.do(func() {
+			if game.Status != "playing" {
+				return
+			}
+			if game.OutOfTime(time.Now()) {
+				a.timeForfeit()
+			} else {
+				// Fired early, or the clock moved on since
+				a.scheduleClock()
+			}
+		})
+	})
+}
+
+func (a *gameActor) stopClock() {
+	if a.clock != nil {
+		a.clock.Stop()
+		a.clock = nil
+	}
+}
+
+// timeForfeit ends a game whose player to move has run out of time.
+func (a *gameActor) timeForfeit() {
+	game := a.game
+	loser := game.CurrentPlayer
+	winner := 3 - loser
+	game.TimedOut = loser
+	game.EndReason = models.EndTimeout
+	game.PauseClock(time.Now())
+
+	log.Printf("Game %s: player %d lost on time", game.ID, loser)
+	a.endGame(&winner)
+}
+
+// pauseClock stops the clock while a player is reconnecting.
+func (a *gameActor) pauseClock() {
+	a.game.PauseClock(time.Now())
+	a.stopClock()
+}
+
+// resumeClock restarts the clock once nobody in the game is still
+// reconnecting.
+func (a *gameActor) resumeClock() {
+	if a.away[1] || a.away[2] {
+		return
+	}
+	a.game.ResumeClock(time.Now())
+	a.scheduleClock()
+}
@@ -0,0 +1,23 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+)
+
+func TestTimeForfeit(t *testing.T) {
+	gm := newTestManager(t)
+	gameID, _, clients := startPvPGame(t, gm, gameOptions{timeControl: &models.TimeControl{PerMove: 1}})
+
+	var ended protocol.GameEnded
+	if err := json.Unmarshal(readUntil(t, clients[2], "game_ended"), &ended); err != nil {
+		t.Fatalf("game_ended: %v", err)
+	}
+	game := ended.GameState
+	if game.ID != gameID || ended.Winner == nil || *ended.Winner != 2 || game.TimedOut != 1 || game.EndReason != models.EndTimeout {
+		t.Errorf("game ended with winner %v, timed out %d, reason %q; want player 1 to lose on time", ended.Winner, game.TimedOut, game.EndReason)
+	}
+}
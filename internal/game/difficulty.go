@@ -0,0 +1,152 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"emitrr-4-in-a-row/internal/rating"
+)
+
+type Difficulty string
+
+const (
+	DifficultyBeginner Difficulty = "beginner"
+	DifficultyCasual   Difficulty = "casual"
+	DifficultyStrong   Difficulty = "strong"
+	DifficultyPerfect  Difficulty = "perfect"
+
+	DefaultDifficulty = DifficultyStrong
+)
+
+type DifficultySettings struct {
+	MaxDepth    int           // 0 = adaptive depth from getOptimalDepth
+	TimeLimit   time.Duration // budget for the whole search, solver included
+	MoveDelay   time.Duration // pause before the bot answers, so it doesn't reply instantly
+	BlunderRate float64       // chance of playing a random move instead of searching
+	EvalNoise   float64       // max random offset added to each root move score
+	UseSolver   bool          // try an exact solve (and the opening book) before the heuristic search
+	Rating      float64       // fixed strength human vs-bot ratings are measured against
+}
+
+// botRatingDeviation keeps bot ratings nearly fixed; they anchor the vs-bot pool.
+const botRatingDeviation = 50
+
+var difficultySettings = map[Difficulty]DifficultySettings{
+	DifficultyBeginner: {
+		MaxDepth:    2,
+		TimeLimit:   200 * time.Millisecond,
+		MoveDelay:   time.Second,
+		BlunderRate: 0.35,
+		EvalNoise:   400,
+		Rating:      900,
+	},
+	DifficultyCasual: {
+		MaxDepth:    5,
+		TimeLimit:   500 * time.Millisecond,
+		MoveDelay:   time.Second,
+		BlunderRate: 0.12,
+		EvalNoise:   120,
+		Rating:      1300,
+	},
+	DifficultyStrong: {
+		MaxDepth:  0,
+		TimeLimit: 2 * time.Second,
+		MoveDelay: time.Second,
+		Rating:    1800,
+	},
+	DifficultyPerfect: {
+		MaxDepth:  boardCells,
+		TimeLimit: 4 * time.Second,
+		MoveDelay: time.Second,
+		UseSolver: true,
+		Rating:    2400,
+	},
+}
+
+// ParseDifficulty maps a client supplied level to a known difficulty,
+// falling back to DefaultDifficulty for empty or unknown values.
+func ParseDifficulty(value string) Difficulty {
+	d := Difficulty(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := difficultySettings[d]; ok {
+		return d
+	}
+	return DefaultDifficulty
+}
+
+func (d Difficulty) Settings() DifficultySettings {
+	if settings, ok := difficultySettings[d]; ok {
+		return settings
+	}
+	return difficultySettings[DefaultDifficulty]
+}
+
+func (d Difficulty) Rating() rating.Rating {
+	return rating.Rating{
+		Rating:     d.Settings().Rating,
+		Deviation:  botRatingDeviation,
+		Volatility: rating.DefaultVolatility,
+	}
+}
+
+func Difficulties() []Difficulty {
+	return []Difficulty{DifficultyBeginner, DifficultyCasual, DifficultyStrong, DifficultyPerfect}
+}
+
+// configureDifficulties applies the BOT_THINK_TIME and BOT_MOVE_DELAY
+// overrides to the built-in settings. A malformed value is ignored as a whole.
+func configureDifficulties(thinkTime, moveDelay string) map[Difficulty]DifficultySettings {
+	settings := make(map[Difficulty]DifficultySettings, len(difficultySettings))
+	for d, s := range difficultySettings {
+		settings[d] = s
+	}
+
+	if times, err := parseBotTimes(thinkTime); err == nil {
+		for d, t := range times {
+			s := settings[d]
+			s.TimeLimit = t
+			settings[d] = s
+		}
+	} else {
+		log.Printf("Ignoring BOT_THINK_TIME: %v", err)
+	}
+
+	if delays, err := parseBotTimes(moveDelay); err == nil {
+		for d, t := range delays {
+			s := settings[d]
+			s.MoveDelay = t
+			settings[d] = s
+		}
+	} else {
+		log.Printf("Ignoring BOT_MOVE_DELAY: %v", err)
+	}
+	return settings
+}
+
+// parseBotTimes reads per-difficulty milliseconds such as
+// "beginner=200,strong=1500". Difficulties left out keep their defaults.
+func parseBotTimes(value string) (map[Difficulty]time.Duration, error) {
+	times := make(map[Difficulty]time.Duration)
+	if strings.TrimSpace(value) == "" {
+		return times, nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		name, ms, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return nil, fmt.Errorf("%q is not difficulty=milliseconds", part)
+		}
+		d := Difficulty(strings.ToLower(strings.TrimSpace(name)))
+		if _, ok := difficultySettings[d]; !ok {
+			return nil, fmt.Errorf("unknown difficulty %q", name)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(ms))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid milliseconds %q for %s", ms, d)
+		}
+		times[d] = time.Duration(n) * time.Millisecond
+	}
+	return times, nil
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"log"
+
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+// gameEvent is one published message, kept encoded so it can be replayed
+// byte for byte.
+type gameEvent struct {
+	seq     int
+	seat    int // 0 if everyone following the game got it, spectators included
+	message []byte
+}
+
+// eventLog holds the latest events of one game, oldest first.
+type eventLog struct {
+	last   int // seq of the latest event
+	events []gameEvent
+}
+
+// publish sends an event to one seat of a game, or to everyone following it
+// when seat is 0, and logs it for clients that reconnect. Only the actor
+// publishes, on its own goroutine.
+func (a *gameActor) publish(seat int, msg protocol.Message) {
+	if message := a.record(seat, msg); message != nil {
+		a.deliver(seat, message)
+	}
+}
+
+// record logs an event and returns it encoded, nil if it couldn't be.
+func (a *gameActor) record(seat int, msg protocol.Message) []byte {
+	events := &a.events
+	message, err := protocol.EncodeEvent(msg, events.last+1)
+	if err != nil {
+		log.Printf("Failed to encode %s: %v", msg.MessageType(), err)
+		return nil
+	}
+	events.last++
+	events.events = append(events.events, gameEvent{seq: events.last, seat: seat, message: message})
+	if over := len(events.events) - a.gm.cfg.EventLogSize; over > 0 {
+		events.events = events.events[over:]
+	}
+	return message
+}
+
+func (a *gameActor) deliver(seat int, message []byte) {
+	for s, conn := range a.conns {
+		if conn != nil && (seat == 0 || seat == s) {
+			a.gm.write(conn, message)
+		}
+	}
+	if seat == 0 {
+		for _, conn := range a.gm.spectators.watchers(a.game.ID) {
+			a.gm.write(conn, message)
+		}
+	}
+}
+
+// missedEvents returns the events seat (0 for spectators) was sent after
+// lastEventSeq and the seq of the latest event. ok is false when there is
+// nothing to catch up from: no lastEventSeq, or one the log no longer
+// reaches back to.
+func (a *gameActor) missedEvents(seat int, lastEventSeq *int) (missed [][]byte, latest int, ok bool) {
+	events := &a.events
+	latest = events.last
+
+	if lastEventSeq == nil || *lastEventSeq > latest {
+		return nil, latest, false
+	}
+	if *lastEventSeq < latest && (len(events.events) == 0 || events.events[0].seq > *lastEventSeq+1) {
+		return nil, latest, false
+	}
+
+	for _, event := range events.events {
+		if event.seq > *lastEventSeq && (event.seat == 0 || event.seat == seat) {
+			missed = append(missed, event.message)
+		}
+	}
+	return missed, latest, true
+}
+
+// sendMissed sends the events a reconnecting client missed, after its
+// snapshot.
+func (gm *GameManager) sendMissed(conn *websocket.Conn, missed [][]byte) {
+	for _, message := range missed {
+		gm.write(conn, message)
+	}
+}
+
+func catchUpMode(ok bool) string {
+	if ok {
+		return "events"
+	}
+	return "snapshot"
+}
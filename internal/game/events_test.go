@@ -0,0 +1,184 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+type frame struct {
+	Type     string          `json:"type"`
+	EventSeq int             `json:"eventSeq"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// nextFrame reads frames off client until one of type msgType arrives.
+func nextFrame(t *testing.T, client *websocket.Conn, msgType string) frame {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var f frame
+		if err := client.ReadJSON(&f); err != nil {
+			t.Fatalf("waiting for %s: %v", msgType, err)
+		}
+		if f.Type == msgType {
+			return f
+		}
+	}
+}
+
+// loggedFrames reads client until it goes quiet and returns the game events
+// it got, leaving out messages that aren't logged. client can't be read
+// from afterwards.
+func loggedFrames(client *websocket.Conn) []frame {
+	var frames []frame
+	for {
+		client.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		var f frame
+		if err := client.ReadJSON(&f); err != nil {
+			return frames
+		}
+		if f.EventSeq > 0 {
+			frames = append(frames, f)
+		}
+	}
+}
+
+func playMove(t *testing.T, gm *GameManager, conn *websocket.Conn, gameID string, column int) {
+	t.Helper()
+	if _, perr := gm.HandlePlayerMove(conn, "", protocol.MakeMove{GameID: gameID, Column: &column}); perr != nil {
+		t.Fatalf("move %d: %v", column, perr)
+	}
+}
+
+// dropPlayer closes a player's connection as far as gm is concerned and
+// returns a session token to rejoin with.
+func dropPlayer(t *testing.T, gm *GameManager, conn *websocket.Conn) string {
+	t.Helper()
+	gm.mu.RLock()
+	player := gm.connections[conn]
+	gm.mu.RUnlock()
+
+	gm.HandlePlayerDisconnect(conn)
+	return gm.sessionToken(player.ID, player.GameID, player.PlayerNum)
+}
+
+// rejoinGame takes a dropped player's seat back on a new connection,
+// catching up from lastEventSeq.
+func rejoinGame(t *testing.T, gm *GameManager, token string, lastEventSeq int) (*websocket.Conn, protocol.GameRejoined) {
+	t.Helper()
+	server, client := connect(t, gm)
+	if perr := gm.HandleRejoin(server, protocol.RejoinGame{SessionToken: token, LastEventSeq: &lastEventSeq}); perr != nil {
+		t.Fatalf("rejoin: %v", perr)
+	}
+
+	var rejoined protocol.GameRejoined
+	if err := json.Unmarshal(nextFrame(t, client, "game_rejoined").Data, &rejoined); err != nil {
+		t.Fatalf("game_rejoined: %v", err)
+	}
+	return client, rejoined
+}
+
+func TestRejoinCatchesUpFromEventLog(t *testing.T) {
+	gm := newTestManager(t)
+	gameID, servers, clients := startPvPGame(t, gm, gameOptions{})
+
+	playMove(t, gm, servers[1], gameID, 0)
+	seen := nextFrame(t, clients[1], "move_made").EventSeq
+
+	// Player 1 drops, player 2 hears about it and moves
+	token := dropPlayer(t, gm, servers[1])
+	disconnected := nextFrame(t, clients[2], "player_disconnected").EventSeq
+	playMove(t, gm, servers[2], gameID, 1)
+	latest := nextFrame(t, clients[2], "move_made").EventSeq
+
+	client, rejoined := rejoinGame(t, gm, token, seen)
+	if rejoined.CatchUp != "events" || rejoined.EventSeq != latest {
+		t.Errorf("caught up by %s to %d, want events to %d", rejoined.CatchUp, rejoined.EventSeq, latest)
+	}
+
+	// Only player 2's move; the disconnect notice was for player 2 alone
+	missed := loggedFrames(client)
+	if len(missed) != 1 || missed[0].Type != "move_made" || missed[0].EventSeq != latest {
+		t.Errorf("missed %+v, want just move_made %d and not player_disconnected %d", missed, latest, disconnected)
+	}
+}
+
+func TestRejoinFallsBackToSnapshot(t *testing.T) {
+	gm := newTestManager(t)
+	gm.cfg.EventLogSize = 2
+	gameID, servers, clients := startPvPGame(t, gm, gameOptions{})
+
+	playMove(t, gm, servers[1], gameID, 0)
+	seen := nextFrame(t, clients[1], "move_made").EventSeq
+	playMove(t, gm, servers[2], gameID, 1)
+	playMove(t, gm, servers[1], gameID, 2)
+	token := dropPlayer(t, gm, servers[1])
+	playMove(t, gm, servers[2], gameID, 3)
+
+	var latest int
+	for i := 0; i < 4; i++ {
+		latest = nextFrame(t, clients[2], "move_made").EventSeq
+	}
+
+	// The log only reaches back to the disconnect notice, long after the
+	// move player 1 last saw
+	client, rejoined := rejoinGame(t, gm, token, seen)
+	if rejoined.CatchUp != "snapshot" || rejoined.EventSeq != latest || len(rejoined.GameState.Moves) != 4 {
+		t.Errorf("caught up by %s to %d with %d moves, want a snapshot at %d with 4 moves",
+			rejoined.CatchUp, rejoined.EventSeq, len(rejoined.GameState.Moves), latest)
+	}
+	if missed := loggedFrames(client); len(missed) != 0 {
+		t.Errorf("events replayed on top of the snapshot: %+v", missed)
+	}
+}
+
+func TestSpectatorCatchesUp(t *testing.T) {
+	gm := newTestManager(t)
+	gameID, servers, clients := startPvPGame(t, gm, gameOptions{})
+
+	watcher, watcherClient := connect(t, gm)
+	if perr := gm.HandleSpectate(watcher, protocol.SpectateGame{GameID: gameID}); perr != nil {
+		t.Fatalf("spectate: %v", perr)
+	}
+	playMove(t, gm, servers[1], gameID, 0)
+	seen := nextFrame(t, watcherClient, "move_made").EventSeq
+	nextFrame(t, clients[2], "move_made")
+	gm.HandlePlayerDisconnect(watcher)
+
+	playMove(t, gm, servers[2], gameID, 1)
+	latest := nextFrame(t, clients[2], "move_made").EventSeq
+	if perr := gm.HandleOfferDraw(servers[1], protocol.OfferDraw{GameID: gameID}); perr != nil {
+		t.Fatalf("offer draw: %v", perr)
+	}
+
+	again, client := connect(t, gm)
+	if perr := gm.HandleSpectate(again, protocol.SpectateGame{GameID: gameID, LastEventSeq: &seen}); perr != nil {
+		t.Fatalf("spectate again: %v", perr)
+	}
+	var spectating protocol.Spectating
+	json.Unmarshal(nextFrame(t, client, "spectating").Data, &spectating)
+	if spectating.CatchUp != "events" {
+		t.Errorf("caught up by %s, want events", spectating.CatchUp)
+	}
+
+	// Player 2's move, but not the draw offer made to player 2 alone
+	missed := loggedFrames(client)
+	if len(missed) != 1 || missed[0].Type != "move_made" || missed[0].EventSeq != latest {
+		t.Errorf("missed %+v, want just move_made %d", missed, latest)
+	}
+
+	// With nothing to catch up from there is only the snapshot
+	fresh, freshClient := connect(t, gm)
+	if perr := gm.HandleSpectate(fresh, protocol.SpectateGame{GameID: gameID}); perr != nil {
+		t.Fatalf("spectate: %v", perr)
+	}
+	json.Unmarshal(nextFrame(t, freshClient, "spectating").Data, &spectating)
+	if spectating.CatchUp != "snapshot" {
+		t.Errorf("new spectator caught up by %s, want a snapshot", spectating.CatchUp)
+	}
+}
@@ -0,0 +1,200 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+const analysisTimeLimit = 1500 * time.Millisecond
+
+var (
+	ErrGameNotFound   = errors.New("Game not found")
+	ErrHintsDisabled  = errors.New("Hints are only available against the bot")
+	ErrGameInProgress = errors.New("Games can only be analysed once they are finished")
+	ErrAnalysisBusy   = errors.New("Analysis service is busy, try again shortly")
+)
+
+type PositionAnalysis struct {
+	Analysis
+	GameID    string  `json:"gameId,omitempty"`
+	MoveIndex *int    `json:"moveIndex,omitempty"`
+	Board     [][]int `json:"board"`
+}
+
+func (gm *GameManager) HandleHintRequest(conn *websocket.Conn, requestID string, msg protocol.RequestHint) *protocol.Error {
+	gameID := msg.GameID
+
+	gm.mu.RLock()
+	player, exists := gm.connections[conn]
+	if !exists {
+		gm.mu.RUnlock()
+		return protocol.NewError(protocol.ErrNotFound, "Player not found")
+	}
+
+	a, exists := gm.games[gameID]
+	if !exists || player.GameID != gameID {
+		gm.mu.RUnlock()
+		return protocol.NewError(protocol.ErrNotFound, ErrGameNotFound.Error())
+	}
+	seat, username := player.PlayerNum, player.Username
+	gm.mu.RUnlock()
+
+	var perr *protocol.Error
+	var board [][]int
+	var event map[string]interface{}
+	gm.inGame(a, func() {
+		game := a.game
+		if game.Status != "playing" || game.CurrentPlayer != seat {
+			perr = protocol.NewError(protocol.ErrNotYourTurn, "Not your turn")
+			return
+		}
+		if !game.IsBot {
+			perr = protocol.NewError(protocol.ErrForbidden, ErrHintsDisabled.Error())
+			return
+		}
+
+		game.HintsUsed++
+		board = copyBoard(game.Board)
+		event = map[string]interface{}{
+			"gameId":    gameID,
+			"player":    username,
+			"moveIndex": len(game.Moves),
+			"hintsUsed": game.HintsUsed,
+			"rated":     game.Rated,
+			"source":    "websocket",
+		}
+	})
+	if perr != nil {
+		return perr
+	}
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("hint_requested", event)
+	}
+
+	// Search without holding the manager lock
+	go func() {
+		analysis, err := gm.analyze(board)
+		if errors.Is(err, ErrAnalysisBusy) {
+			gm.sendError(conn, requestID, protocol.ErrBusy, err.Error())
+			return
+		}
+		if err != nil {
+			gm.sendError(conn, requestID, protocol.ErrInternal, err.Error())
+			return
+		}
+		gm.sendMessage(conn, &protocol.Hint{
+			GameID:       gameID,
+			Board:        board,
+			ColumnScores: analysis.ColumnScores,
+			BestMove:     analysis.BestMove,
+			Outcome:      analysis.Outcome,
+			Plies:        analysis.Plies,
+			Exact:        analysis.Exact,
+			Depth:        analysis.Depth,
+			SideToMove:   analysis.SideToMove,
+		})
+	}()
+	return nil
+}
+
+// AnalyzeBoard analyses an arbitrary position submitted through the API.
+func (gm *GameManager) AnalyzeBoard(board [][]int) (*PositionAnalysis, error) {
+	analysis, err := gm.analyze(board)
+	if err != nil {
+		return nil, err
+	}
+
+	gm.trackAnalysis("", nil)
+	return &PositionAnalysis{Analysis: analysis, Board: board}, nil
+}
+
+// AnalyzeGameMove analyses the position after the first moveIndex moves of a
+// game. Games can only be analysed once they are finished; hints during bot
+// games go through request_hint so they are counted.
+func (gm *GameManager) AnalyzeGameMove(gameID string, moveIndex int) (*PositionAnalysis, error) {
+	gm.mu.RLock()
+	a, exists := gm.games[gameID]
+	if exists && !a.ended {
+		gm.mu.RUnlock()
+		return nil, ErrGameInProgress
+	}
+	gm.mu.RUnlock()
+
+	record, err := gm.GetGameRecord(gameID)
+	if err != nil {
+		return nil, err
+	}
+	board, err := models.BoardFromMoves(record.MoveHistory, moveIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis, err := gm.analyze(board)
+	if err != nil {
+		return nil, err
+	}
+
+	gm.trackAnalysis(gameID, &moveIndex)
+	return &PositionAnalysis{
+		Analysis:  analysis,
+		GameID:    gameID,
+		MoveIndex: &moveIndex,
+		Board:     board,
+	}, nil
+}
+
+func (gm *GameManager) analyze(board [][]int) (Analysis, error) {
+	pos, err := ValidateBoard(board)
+	if err != nil {
+		return Analysis{}, &models.GameError{Message: err.Error()}
+	}
+
+	// Each search borrows one of a fixed set of engines
+	var bot *Bot
+	select {
+	case bot = <-gm.analysisBots:
+		defer func() { gm.analysisBots <- bot }()
+	default:
+		return Analysis{}, ErrAnalysisBusy
+	}
+	if bot == nil {
+		bot = gm.newAnalysisBot()
+	}
+	return bot.Analyze(context.Background(), pos, analysisTimeLimit), nil
+}
+
+// newAnalysisBot creates an engine for the analysis pool, sized within
+// BotMemoryLimitMB like the bots playing games.
+func (gm *GameManager) newAnalysisBot() *Bot {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	bot := gm.newBot(DifficultyPerfect)
+	gm.analysisEngines = append(gm.analysisEngines, bot)
+	return bot
+}
+
+func (gm *GameManager) trackAnalysis(gameID string, moveIndex *int) {
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("analysis_requested", map[string]interface{}{
+			"gameId":    gameID,
+			"moveIndex": moveIndex,
+			"source":    "api",
+		})
+	}
+}
+
+func copyBoard(board [][]int) [][]int {
+	copied := make([][]int, len(board))
+	for i := range board {
+		copied[i] = append([]int(nil), board[i]...)
+	}
+	return copied
+}
@@ -0,0 +1,41 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAnalyzeGameMoveRefusesLiveGames(t *testing.T) {
+	gm := newTestManager(t)
+	server, _ := connect(t, gm)
+
+	gm.mu.Lock()
+	player := &Player{ID: newPlayerID(), Username: "alice", Conn: server, Difficulty: DifficultyBeginner}
+	gm.connections[server] = player
+	gm.startBotGame(player, gameOptions{})
+	gameID := player.GameID
+	gm.mu.Unlock()
+
+	if _, err := gm.AnalyzeGameMove(gameID, 0); !errors.Is(err, ErrGameInProgress) {
+		t.Errorf("analysing a live bot game: %v, want %v", err, ErrGameInProgress)
+	}
+}
+
+func TestAnalyzeBoardReusesEngines(t *testing.T) {
+	gm := newTestManager(t)
+	board := benchBoard()
+
+	for i := 0; i < 3; i++ {
+		if _, err := gm.AnalyzeBoard(board); err != nil {
+			t.Fatalf("analysis %d: %v", i, err)
+		}
+	}
+
+	stats := gm.BotStats()
+	if stats.AnalysisBots != 1 {
+		t.Errorf("%d analysis engines after three analyses one at a time, want 1", stats.AnalysisBots)
+	}
+	if stats.Tables.Bytes == 0 {
+		t.Error("analysis engine tables missing from bot stats")
+	}
+}
@@ -1,51 +1,124 @@
 package game
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"math"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"emitrr-4-in-a-row/internal/config"
 	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+	"emitrr-4-in-a-row/internal/rating"
 	"emitrr-4-in-a-row/internal/services"
 
 	"github.com/gorilla/websocket"
 )
 
 type GameManager struct {
-	games            map[string]*models.Game
+	games            map[string]*gameActor
 	connections      map[*websocket.Conn]*Player
-	waitingQueue     []*Player
-	disconnected     map[string]*DisconnectedInfo
-	dbService        *services.DatabaseService
+	queue            matchQueue
+	rooms            map[string]*Room
+	spectators       spectatorSet
+	clients          clientSet
+	disconnected     map[string]*DisconnectedInfo // keyed by player ID
+	dbService        services.Storage
 	analyticsService *services.AnalyticsService
-	bot              *Bot
+	cfg              *config.Config
+	bots             map[string]*Bot // the engines of live bot games, keyed by game ID
+	book             *OpeningBook
+	analysisBots     chan *Bot // idle analysis engines, nil until first used
+	analysisEngines  []*Bot    // every analysis engine created so far
+	reviews          map[string]*GameReview
+	reviewQueue      chan reviewJob
+	rematches        map[string]*rematchOffer // keyed by the finished game's ID
+	timeControl      *models.TimeControl      // for matchmade and bot games
+	difficulties     map[Difficulty]DifficultySettings
+	sessionKey       []byte // signs reconnect tokens
 	mu               sync.RWMutex
 }
 
 type Player struct {
-	Username  string
-	Conn      *websocket.Conn
-	GameID    string
-	PlayerNum int
+	ID         string // this session's identity, see newPlayerID
+	Username   string
+	Conn       *websocket.Conn
+	GameID     string
+	PlayerNum  int
+	Difficulty Difficulty
+	Rating     rating.Rating // PvP
+	BotRating  rating.Rating
+	RoomCode   string // set while waiting in a private room
+}
+
+// gameOptions covers how a game came about beyond who is playing it.
+type gameOptions struct {
+	private     bool
+	rated       bool
+	humanSeat   int // bot games only; the human moves first unless this is 2
+	series      *models.Series
+	timeControl *models.TimeControl
 }
 
 type DisconnectedInfo struct {
-	GameID    string
-	PlayerNum int
-	Time      time.Time
+	Username   string
+	GameID     string
+	PlayerNum  int
+	Difficulty Difficulty
+	Rating     rating.Rating
+	BotRating  rating.Rating
+	Time       time.Time
+}
+
+type BotStats struct {
+	ActiveBots    int             `json:"activeBots"`
+	AnalysisBots  int             `json:"analysisBots"`
+	TableSizeMB   int             `json:"tableSizeMB"`
+	MemoryLimitMB int             `json:"memoryLimitMB"`
+	Tables        TransTableStats `json:"tables"`
 }
 
-func NewGameManager(dbService *services.DatabaseService, analyticsService *services.AnalyticsService) *GameManager {
+func NewGameManager(cfg *config.Config, dbService services.Storage, analyticsService *services.AnalyticsService) *GameManager {
 	gm := &GameManager{
-		games:            make(map[string]*models.Game),
+		games:            make(map[string]*gameActor),
 		connections:      make(map[*websocket.Conn]*Player),
-		waitingQueue:     make([]*Player, 0),
+		rooms:            make(map[string]*Room),
+		spectators:       spectatorSet{byConn: make(map[*websocket.Conn]*spectator)},
+		clients:          clientSet{byConn: make(map[*websocket.Conn]*client)},
 		disconnected:     make(map[string]*DisconnectedInfo),
 		dbService:        dbService,
 		analyticsService: analyticsService,
-		bot:              NewBot(),
+		cfg:              cfg,
+		bots:             make(map[string]*Bot),
+		analysisBots:     make(chan *Bot, runtime.NumCPU()),
+		reviews:          make(map[string]*GameReview),
+		reviewQueue:      make(chan reviewJob, reviewQueueSize),
+		rematches:        make(map[string]*rematchOffer),
+		sessionKey:       newSessionKey(cfg.SessionSecret),
+	}
+
+	if tc, err := models.ParseTimeControl(cfg.TimeControl); err == nil {
+		gm.timeControl = tc
+	} else {
+		log.Printf("Ignoring TIME_CONTROL, games will be untimed: %v", err)
+	}
+	gm.difficulties = configureDifficulties(cfg.BotThinkTime, cfg.BotMoveDelay)
+	for i := 0; i < cap(gm.analysisBots); i++ {
+		gm.analysisBots <- nil
+	}
+
+	if book, err := LoadOpeningBook(cfg.OpeningBookPath); err == nil {
+		gm.book = book
+		log.Printf("Opening book loaded: %d positions, depth %d", book.Len(), book.Depth())
+	} else {
+		log.Printf("Opening book unavailable, perfect bot will solve from scratch: %v", err)
 	}
+	gm.startReviewWorkers()
+	gm.startMatchmaking()
 
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
@@ -58,184 +131,273 @@ func NewGameManager(dbService *services.DatabaseService, analyticsService *servi
 	return gm
 }
 
-func (gm *GameManager) HandlePlayerJoin(conn *websocket.Conn, data map[string]interface{}) {
-	username, ok := data["username"].(string)
-	if !ok || len(strings.TrimSpace(username)) < 2 {
-		gm.sendError(conn, "Invalid username")
-		return
+func (gm *GameManager) HandlePlayerJoin(conn *websocket.Conn, msg protocol.JoinGame) *protocol.Error {
+	username, ok := parseUsername(msg.Username)
+	if !ok {
+		return protocol.NewError(protocol.ErrInvalidUsername, "Invalid username")
 	}
-	username = strings.TrimSpace(username)
+	difficulty := ParseDifficulty(msg.Difficulty)
+
+	pvpRating, botRating := gm.loadRatings(username)
 
 	gm.mu.Lock()
-	defer gm.mu.Unlock()
+	if gm.spectators.isSpectating(conn) {
+		gm.mu.Unlock()
+		return protocol.NewError(protocol.ErrConflict, "Already spectating a game")
+	}
 
-	// Check for reconnection
-	if info, exists := gm.disconnected[username]; exists {
-		if time.Since(info.Time).Seconds() <= 30 {
-			gm.reconnectPlayer(conn, username, info)
-			return
+	// Queued, waiting in a room or playing; a finished game can be left for a new one
+	if current, busy := gm.connections[conn]; busy {
+		a, exists := gm.games[current.GameID]
+		if current.GameID == "" || (exists && !a.ended) {
+			gm.mu.Unlock()
+			return protocol.NewError(protocol.ErrConflict, "Already in a game or queue")
 		}
-		delete(gm.disconnected, username)
+		gm.leaveRematch(current)
+		delete(gm.connections, conn)
 	}
 
 	player := &Player{
-		Username: username,
-		Conn:     conn,
+		ID:         newPlayerID(),
+		Username:   username,
+		Conn:       conn,
+		Difficulty: difficulty,
+		Rating:     pvpRating,
+		BotRating:  botRating,
 	}
 	gm.connections[conn] = player
 
-	// Try to match with waiting player
-	if len(gm.waitingQueue) > 0 {
-		opponent := gm.waitingQueue[0]
-		gm.waitingQueue = gm.waitingQueue[1:]
-		gm.startPvPGame(opponent, player)
-		return
-	}
+	gm.enqueue(player)
+	gm.mu.Unlock()
+	return nil
+}
 
-	// Add to waiting queue
-	gm.waitingQueue = append(gm.waitingQueue, player)
-	gm.sendMessage(conn, "waiting_for_opponent", nil)
+// HandlePlayerMove plays a move and returns the ack for it. A retried
+// request ID is acknowledged again without playing the move twice.
+func (gm *GameManager) HandlePlayerMove(conn *websocket.Conn, requestID string, msg protocol.MakeMove) (*protocol.Ack, *protocol.Error) {
+	gameID := msg.GameID
 
-	// Start bot game after 10 seconds
-	go func() {
-		time.Sleep(10 * time.Second)
-		gm.mu.Lock()
-		defer gm.mu.Unlock()
-		
-		for i, p := range gm.waitingQueue {
-			if p == player {
-				gm.waitingQueue = append(gm.waitingQueue[:i], gm.waitingQueue[i+1:]...)
-				gm.startBotGame(player)
-				return
-			}
+	gm.mu.RLock()
+	player, exists := gm.connections[conn]
+	if !exists {
+		gm.mu.RUnlock()
+		if gm.spectators.isSpectating(conn) {
+			return nil, protocol.NewError(protocol.ErrForbidden, "Spectators can't make moves")
 		}
-	}()
-}
+		return nil, protocol.NewError(protocol.ErrNotFound, "Player not found")
+	}
 
-func (gm *GameManager) HandlePlayerMove(conn *websocket.Conn, data map[string]interface{}) {
-	gameID, ok := data["gameId"].(string)
-	if !ok {
-		gm.sendError(conn, "Invalid game ID")
-		return
+	a, exists := gm.games[gameID]
+	if !exists || player.GameID != gameID {
+		gm.mu.RUnlock()
+		return nil, protocol.NewError(protocol.ErrNotFound, "Game not found")
 	}
+	seat, username := player.PlayerNum, player.Username
+	gm.mu.RUnlock()
 
-	columnFloat, ok := data["column"].(float64)
-	if !ok {
-		gm.sendError(conn, "Invalid column")
-		return
+	var ack *protocol.Ack
+	var perr *protocol.Error
+	gm.inGame(a, func() {
+		ack, perr = a.move(seat, username, requestID, msg)
+	})
+	return ack, perr
+}
+
+func (a *gameActor) move(seat int, username, requestID string, msg protocol.MakeMove) (*protocol.Ack, *protocol.Error) {
+	game, column := a.game, *msg.Column
+
+	requestKey := fmt.Sprintf("%d/%s", seat, requestID)
+	if seq, done := a.moveRequests[requestKey]; done && requestID != "" {
+		return &protocol.Ack{Seq: seq, Duplicate: true}, nil
 	}
-	column := int(columnFloat)
 
-	gm.mu.Lock()
-	defer gm.mu.Unlock()
+	if game.Status != "playing" {
+		return nil, protocol.NewError(protocol.ErrGameNotActive, "Game not active")
+	}
 
-	player, exists := gm.connections[conn]
-	if !exists {
-		gm.sendError(conn, "Player not found")
-		return
+	if msg.Seq != nil && *msg.Seq != game.Seq {
+		return nil, protocol.NewError(protocol.ErrStaleSeq,
+			fmt.Sprintf("Move was made at seq %d but the game is at %d", *msg.Seq, game.Seq))
 	}
 
-	game, exists := gm.games[gameID]
-	if !exists {
-		gm.sendError(conn, "Game not found")
-		return
+	if game.CurrentPlayer != seat {
+		return nil, protocol.NewError(protocol.ErrNotYourTurn, "Not your turn")
 	}
 
-	if game.CurrentPlayer != player.PlayerNum {
-		gm.sendError(conn, "Not your turn")
-		return
+	if game.OutOfTime(time.Now()) {
+		a.timeForfeit()
+		return nil, protocol.NewError(protocol.ErrGameNotActive, "Out of time")
 	}
 
-	row, gameOver, winner, err := game.MakeMove(column, player.PlayerNum)
+	row, gameOver, winner, err := game.MakeMove(column, seat)
 	if err != nil {
-		gm.sendError(conn, err.Error())
-		return
+		return nil, protocol.NewError(protocol.ErrInvalidMove, err.Error())
 	}
 
-	moveData := map[string]interface{}{
-		"column":    column,
-		"row":       row,
-		"player":    player.PlayerNum,
-		"gameState": game,
+	if requestID != "" {
+		a.moveRequests[requestKey] = game.Seq
 	}
-	gm.broadcastToGame(gameID, "move_made", moveData)
+
+	a.clearOffers()
+	a.publish(0, &protocol.MoveMade{
+		Seq:       game.Seq,
+		Column:    column,
+		Row:       row,
+		Player:    seat,
+		GameState: game,
+		Clock:     game.ClockState(time.Now()),
+	})
+	a.scheduleClock()
 
 	// Analytics
-	if gm.analyticsService != nil {
-		gm.analyticsService.TrackEvent("move_made", map[string]interface{}{
-			"gameId": gameID,
-			"player": player.Username,
+	if a.gm.analyticsService != nil {
+		a.gm.analyticsService.TrackEvent("move_made", map[string]interface{}{
+			"gameId": game.ID,
+			"player": username,
 			"column": column,
 			"row":    row,
 		})
 	}
 
+	seq := game.Seq
 	if gameOver {
-		gm.endGame(game, winner)
-	} else if game.IsBot && game.CurrentPlayer == 2 {
-		go func() {
-			time.Sleep(1 * time.Second)
-			gm.makeBotMove(game)
-		}()
+		a.endGame(winner)
+	} else if game.IsBot && game.CurrentPlayer == botSeat(game) {
+		a.scheduleBotMove()
 	}
+	return &protocol.Ack{Seq: seq}, nil
+}
+
+// HandleResync sends a player or spectator of a game its current state.
+func (gm *GameManager) HandleResync(conn *websocket.Conn, msg protocol.Resync) *protocol.Error {
+	gm.mu.RLock()
+	a, exists := gm.games[msg.GameID]
+	if !exists {
+		gm.mu.RUnlock()
+		return protocol.NewError(protocol.ErrNotFound, "Game not found")
+	}
+
+	seat := 0
+	if player, playing := gm.connections[conn]; playing && player.GameID == msg.GameID {
+		seat = player.PlayerNum
+	} else if gm.spectators.watching(conn) != msg.GameID {
+		gm.mu.RUnlock()
+		return protocol.NewError(protocol.ErrForbidden, "Not playing or watching this game")
+	}
+	gm.mu.RUnlock()
+
+	gm.inGame(a, func() {
+		gm.sendMessage(conn, &protocol.GameState{
+			GameState:  a.game,
+			YourPlayer: seat,
+			Clock:      a.game.ClockState(time.Now()),
+		})
+	})
+	return nil
 }
 
 func (gm *GameManager) HandlePlayerDisconnect(conn *websocket.Conn) {
+	if c := gm.clients.remove(conn); c != nil {
+		gm.trackLatency(conn, c)
+	}
+
+	a, player := gm.dropConnection(conn)
+	if a == nil {
+		return
+	}
+
+	detached := false
+	gm.inGame(a, func() {
+		detached = a.detach(player.PlayerNum, conn)
+	})
+	if !detached {
+		// The game ended before the actor heard about it
+		gm.mu.Lock()
+		gm.leaveRematch(player)
+		gm.mu.Unlock()
+	}
+}
+
+// dropConnection forgets a closed connection. It returns the game the
+// player left, if that is still going and now waits for them to reconnect.
+func (gm *GameManager) dropConnection(conn *websocket.Conn) (*gameActor, *Player) {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 
+	if gm.stopSpectating(conn) {
+		return nil, nil
+	}
+
 	player, exists := gm.connections[conn]
 	if !exists {
-		return
+		return nil, nil
 	}
 
 	delete(gm.connections, conn)
 
-	// Remove from waiting queue
-	for i, p := range gm.waitingQueue {
-		if p == player {
-			gm.waitingQueue = append(gm.waitingQueue[:i], gm.waitingQueue[i+1:]...)
-			return
-		}
+	// Remove from matchmaking
+	if gm.dequeue(player) {
+		return nil, nil
+	}
+
+	if room, exists := gm.rooms[player.RoomCode]; exists {
+		gm.closeRoom(room)
+		return nil, nil
 	}
 
 	// Handle game disconnect
 	if player.GameID != "" {
-		game, exists := gm.games[player.GameID]
-		if exists && game.Status == "playing" {
-			gm.disconnected[player.Username] = &DisconnectedInfo{
-				GameID:    player.GameID,
-				PlayerNum: player.PlayerNum,
-				Time:      time.Now(),
+		a, exists := gm.games[player.GameID]
+		if exists && !a.ended {
+			gm.disconnected[player.ID] = &DisconnectedInfo{
+				Username:   player.Username,
+				GameID:     player.GameID,
+				PlayerNum:  player.PlayerNum,
+				Difficulty: player.Difficulty,
+				Rating:     player.Rating,
+				BotRating:  player.BotRating,
+				Time:       time.Now(),
 			}
-			gm.notifyDisconnect(game, player)
+			return a, player
 		}
+		gm.leaveRematch(player)
 	}
+	return nil, nil
 }
 
-func (gm *GameManager) startPvPGame(player1, player2 *Player) {
+// startPvPGame starts a game between two people, whether matched from the
+// queue, brought together by a private room or rematching.
+func (gm *GameManager) startPvPGame(player1, player2 *Player, opts gameOptions) *models.Game {
 	game := models.NewGame(
-		&models.Player{ID: "p1", Username: player1.Username},
-		&models.Player{ID: "p2", Username: player2.Username},
+		ratedPlayer(&models.Player{ID: player1.ID, Username: player1.Username}, player1.Rating),
+		ratedPlayer(&models.Player{ID: player2.ID, Username: player2.Username}, player2.Rating),
 	)
 	game.Status = "playing"
-	gm.games[game.ID] = game
+	game.Private = opts.private
+	game.Rated = opts.rated
+	game.Series = opts.series
+	game.StartClock(opts.timeControl, time.Now())
+	a := gm.newGameActor(game)
+	a.conns[1], a.conns[2] = player1.Conn, player2.Conn
+	gm.games[game.ID] = a
 
 	player1.GameID = game.ID
 	player1.PlayerNum = 1
 	player2.GameID = game.ID
 	player2.PlayerNum = 2
 
-	gm.sendMessage(player1.Conn, "game_started", map[string]interface{}{
-		"gameState":  game,
-		"yourPlayer": 1,
+	gm.sendMessage(player1.Conn, &protocol.GameStarted{
+		GameState:    game,
+		YourPlayer:   1,
+		SessionToken: gm.sessionToken(player1.ID, game.ID, 1),
 	})
-	gm.sendMessage(player2.Conn, "game_started", map[string]interface{}{
-		"gameState":  game,
-		"yourPlayer": 2,
+	gm.sendMessage(player2.Conn, &protocol.GameStarted{
+		GameState:    game,
+		YourPlayer:   2,
+		SessionToken: gm.sessionToken(player2.ID, game.ID, 2),
 	})
 
-	log.Printf("PvP game started: %s vs %s", player1.Username, player2.Username)
+	log.Printf("%s game started: %s vs %s", gameType(game), player1.Username, player2.Username)
 
 	// Analytics
 	if gm.analyticsService != nil {
@@ -243,81 +405,279 @@ func (gm *GameManager) startPvPGame(player1, player2 *Player) {
 			"gameId":   game.ID,
 			"player1":  player1.Username,
 			"player2":  player2.Username,
-			"gameType": "pvp",
+			"gameType": gameType(game),
+			"rated":    game.Rated,
 		})
 	}
+
+	a.start()
+	return game
 }
 
-func (gm *GameManager) startBotGame(player *Player) {
-	game := models.NewGame(
-		&models.Player{ID: "p1", Username: player.Username},
-		&models.Player{ID: "bot", Username: "AI Bot", IsBot: true},
-	)
+func (gm *GameManager) startBotGame(player *Player, opts gameOptions) *models.Game {
+	human := ratedPlayer(&models.Player{ID: player.ID, Username: player.Username}, player.BotRating)
+	bot := ratedPlayer(&models.Player{ID: "bot", Username: "AI Bot", IsBot: true}, player.Difficulty.Rating())
+	seat := 1
+	game := models.NewGame(human, bot)
+	if opts.humanSeat == 2 {
+		seat = 2
+		game = models.NewGame(bot, human)
+	}
 	game.Status = "playing"
 	game.IsBot = true
-	gm.games[game.ID] = game
+	game.Difficulty = string(player.Difficulty)
+	game.Series = opts.series
+	game.StartClock(opts.timeControl, time.Now())
+	a := gm.newGameActor(game)
+	a.conns[seat] = player.Conn
+	a.bot = gm.newBot(player.Difficulty)
+	gm.games[game.ID] = a
+	gm.bots[game.ID] = a.bot
 
 	player.GameID = game.ID
-	player.PlayerNum = 1
+	player.PlayerNum = seat
 
-	gm.sendMessage(player.Conn, "game_started", map[string]interface{}{
-		"gameState":  game,
-		"yourPlayer": 1,
+	gm.sendMessage(player.Conn, &protocol.GameStarted{
+		GameState:    game,
+		YourPlayer:   seat,
+		Difficulty:   game.Difficulty,
+		SessionToken: gm.sessionToken(player.ID, game.ID, seat),
 	})
 
-	log.Printf("Bot game started for: %s", player.Username)
+	log.Printf("Bot game started for: %s (difficulty: %s)", player.Username, game.Difficulty)
 
 	// Analytics
 	if gm.analyticsService != nil {
 		gm.analyticsService.TrackEvent("game_started", map[string]interface{}{
-			"gameId":   game.ID,
-			"player1":  player.Username,
-			"player2":  "AI Bot",
-			"gameType": "bot",
+			"gameId":     game.ID,
+			"player1":    game.Player1.Username,
+			"player2":    game.Player2.Username,
+			"gameType":   "bot",
+			"difficulty": game.Difficulty,
 		})
 	}
+
+	a.start()
+	return game
 }
 
-func (gm *GameManager) reconnectPlayer(conn *websocket.Conn, username string, info *DisconnectedInfo) {
-	game, exists := gm.games[info.GameID]
-	if !exists || game.Status != "playing" {
-		delete(gm.disconnected, username)
-		gm.sendError(conn, "Game no longer available")
-		return
+// scheduleBotMove lets the bot answer after its difficulty's pause, which
+// comes out of its own clock in timed games. Nothing is played if the game
+// ends first.
+func (a *gameActor) scheduleBotMove() {
+	delay := a.bot.settings.MoveDelay
+	if a.game.Clock != nil {
+		delay = min(delay, a.game.RemainingTime(botSeat(a.game), time.Now())/10)
 	}
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
 
-	player := &Player{
-		Username:  username,
-		Conn:      conn,
-		GameID:    info.GameID,
-		PlayerNum: info.PlayerNum,
+		select {
+		case <-timer.C:
+			a.do(a.makeBotMove)
+		case <-a.ctx.Done():
+		}
+	}()
+}
+
+// botSeat is 1 or 2 for the side the bot plays, 0 in games between people.
+func botSeat(game *models.Game) int {
+	switch {
+	case game.Player1.IsBot:
+		return 1
+	case game.Player2 != nil && game.Player2.IsBot:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// HandleRejoin gives a player whose connection dropped their seat back. The
+// session token from game_started proves the seat is theirs; the username
+// alone proves nothing.
+func (gm *GameManager) HandleRejoin(conn *websocket.Conn, msg protocol.RejoinGame) *protocol.Error {
+	claims, ok := gm.parseSessionToken(msg.SessionToken)
+	if !ok {
+		return protocol.NewError(protocol.ErrForbidden, "Invalid session token")
 	}
-	gm.connections[conn] = player
-	delete(gm.disconnected, username)
 
-	gm.sendMessage(conn, "game_rejoined", map[string]interface{}{
-		"gameState":  game,
-		"yourPlayer": info.PlayerNum,
+	gm.mu.Lock()
+	if _, busy := gm.connections[conn]; busy || gm.spectators.isSpectating(conn) {
+		gm.mu.Unlock()
+		return protocol.NewError(protocol.ErrConflict, "Already in a game or queue")
+	}
+
+	info, exists := gm.disconnected[claims.PlayerID]
+	if !exists || info.GameID != claims.GameID || info.PlayerNum != claims.Seat ||
+		time.Since(info.Time).Seconds() > 30 {
+		gm.mu.Unlock()
+		return protocol.NewError(protocol.ErrNotFound, "No game to rejoin")
+	}
+
+	a, err := gm.rejoin(conn, claims.PlayerID, info)
+	gm.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return gm.reconnectPlayer(conn, a, info, msg.LastEventSeq)
+}
+
+// rejoin gives a reconnecting player back their seat. Callers hold gm.mu.
+func (gm *GameManager) rejoin(conn *websocket.Conn, playerID string, info *DisconnectedInfo) (*gameActor, *protocol.Error) {
+	delete(gm.disconnected, playerID)
+
+	a, exists := gm.games[info.GameID]
+	if !exists || a.ended {
+		return nil, protocol.NewError(protocol.ErrNotFound, "Game no longer available")
+	}
+
+	gm.connections[conn] = &Player{
+		ID:         playerID,
+		Username:   info.Username,
+		Conn:       conn,
+		GameID:     info.GameID,
+		PlayerNum:  info.PlayerNum,
+		Difficulty: info.Difficulty,
+		Rating:     info.Rating,
+		BotRating:  info.BotRating,
+	}
+	return a, nil
+}
+
+func (gm *GameManager) reconnectPlayer(conn *websocket.Conn, a *gameActor, info *DisconnectedInfo, lastEventSeq *int) *protocol.Error {
+	rejoined := false
+	gm.inGame(a, func() {
+		rejoined = a.attach(info.PlayerNum, conn, lastEventSeq)
 	})
+	if !rejoined {
+		gm.mu.Lock()
+		if player, exists := gm.connections[conn]; exists && player.GameID == info.GameID {
+			delete(gm.connections, conn)
+		}
+		gm.mu.Unlock()
+		return protocol.NewError(protocol.ErrNotFound, "Game no longer available")
+	}
+	return nil
+}
+
+// attach seats a reconnecting player and catches them up, reporting false if
+// the game is already over.
+func (a *gameActor) attach(seat int, conn *websocket.Conn, lastEventSeq *int) bool {
+	game := a.game
+	if game.Status != "playing" {
+		return false
+	}
 
-	gm.notifyReconnect(game, player)
-	log.Printf("Player %s reconnected to game %s", username, info.GameID)
+	a.conns[seat] = conn
+	a.away[seat] = false
+	a.resumeClock()
+
+	missed, latest, ok := a.missedEvents(seat, lastEventSeq)
+	a.gm.sendMessage(conn, &protocol.GameRejoined{
+		GameState:  game,
+		YourPlayer: seat,
+		EventSeq:   latest,
+		CatchUp:    catchUpMode(ok),
+	})
+	a.gm.sendMissed(conn, missed)
+
+	a.notifyReconnect(seat)
+	log.Printf("Player %s reconnected to game %s, caught up by %s (%d events)", a.username(seat), game.ID, catchUpMode(ok), len(missed))
+	return true
 }
 
-func (gm *GameManager) makeBotMove(game *models.Game) {
-	gm.mu.Lock()
-	defer gm.mu.Unlock()
+// detach pauses the game while seat's player reconnects, unless conn has
+// already been replaced by a newer connection.
+func (a *gameActor) detach(seat int, conn *websocket.Conn) bool {
+	if a.game.Status != "playing" {
+		return false
+	}
+	if a.conns[seat] != conn {
+		return true
+	}
 
-	if game.Status != "playing" || game.CurrentPlayer != 2 {
+	a.conns[seat] = nil
+	a.away[seat] = true
+	a.pauseClock()
+	a.notifyDisconnect(seat)
+	return true
+}
+
+// abandon ends the game against a player who never came back.
+func (a *gameActor) abandon(seat int) {
+	game := a.game
+	if game.Status != "playing" || !a.away[seat] {
+		return
+	}
+
+	winner := 3 - seat
+	game.AbandonedBy = seat
+	game.EndReason = models.EndAbandonment
+	a.endGame(&winner)
+}
+
+// makeBotMove sets the bot thinking about the current position. The search
+// runs on its own goroutine so the game keeps taking commands meanwhile: a
+// resign, abandonment or flag fall cancels it through a.ctx, and a takeback
+// cancels it and makes its answer stale.
+func (a *gameActor) makeBotMove() {
+	game := a.game
+	seat := botSeat(game)
+	if game.Status != "playing" || game.CurrentPlayer != seat {
+		return
+	}
+	// Still unwinding a cancelled search; it starts the next one when done
+	if a.thinking != nil {
+		return
+	}
+
+	if game.OutOfTime(time.Now()) {
+		a.timeForfeit()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.thinking = cancel
+	pos, seq := PositionFromBoard(game.Board), game.Seq
+	timeLimit := a.bot.thinkTime(game)
+
+	go func() {
+		defer cancel()
+		start := time.Now()
+		column := a.bot.GetBestMove(ctx, pos, timeLimit)
+		thought := time.Since(start)
+		a.do(func() { a.playBotMove(seq, column, thought) })
+	}()
+}
+
+// stopThinking cancels the bot's search, if it is thinking.
+func (a *gameActor) stopThinking() {
+	if a.thinking != nil {
+		a.thinking()
+	}
+}
+
+// playBotMove plays the column the bot settled on for the position at seq.
+func (a *gameActor) playBotMove(seq, column int, thought time.Duration) {
+	game := a.game
+	seat := botSeat(game)
+	a.thinking = nil
+	if game.Status != "playing" || game.CurrentPlayer != seat {
+		return
+	}
+	if game.Seq != seq {
+		// Taken back while the bot was thinking, and its turn again since
+		a.makeBotMove()
 		return
 	}
 
-	column := gm.bot.GetBestMove(game)
 	if column < 0 {
 		log.Printf("Bot could not find valid move, game may be full")
 		// Check if board is full (draw)
 		if game.IsBoardFull() {
-			gm.endGame(game, nil) // Draw
+			game.EndReason = models.EndBoardFull
+			a.endGame(nil) // Draw
 		}
 		return
 	}
@@ -333,147 +693,371 @@ func (gm *GameManager) makeBotMove(game *models.Game) {
 		return
 	}
 
-	row, gameOver, winner, err := game.MakeMove(column, 2)
+	row, gameOver, winner, err := game.MakeMove(column, seat)
 	if err != nil {
 		log.Printf("Bot move error: %v", err)
 		return
 	}
 
-	moveData := map[string]interface{}{
-		"column":    column,
-		"row":       row,
-		"player":    2,
-		"gameState": game,
-	}
-	gm.broadcastToGame(game.ID, "move_made", moveData)
+	a.clearOffers()
+	a.publish(0, &protocol.MoveMade{
+		Seq:       game.Seq,
+		Column:    column,
+		Row:       row,
+		Player:    seat,
+		GameState: game,
+		Clock:     game.ClockState(time.Now()),
+	})
+	a.scheduleClock()
 
 	// Analytics
-	if gm.analyticsService != nil {
-		gm.analyticsService.TrackEvent("bot_move", map[string]interface{}{
-			"gameId": game.ID,
-			"column": column,
-			"row":    row,
+	if a.gm.analyticsService != nil {
+		a.gm.analyticsService.TrackEvent("bot_move", map[string]interface{}{
+			"gameId":  game.ID,
+			"column":  column,
+			"row":     row,
+			"thinkMs": thought.Milliseconds(),
 		})
 	}
 
 	if gameOver {
-		gm.endGame(game, winner)
+		a.endGame(winner)
 	}
 }
 
-func (gm *GameManager) endGame(game *models.Game, winner *int) {
+// endGame finishes the game, hands it back to the manager and stops the
+// actor, cancelling its clock and whatever the bot is thinking or about
+// to play.
+func (a *gameActor) endGame(winner *int) {
+	gm, game := a.gm, a.game
 	game.Status = "finished"
 	game.Winner = winner
-
-	endData := map[string]interface{}{
-		"winner":    winner,
-		"gameState": game,
+	a.over = true
+	a.cancel()
+	a.stopClock()
+	a.clearOffers()
+	if game.Series != nil {
+		game.Series.Record(game)
 	}
-	gm.broadcastToGame(game.ID, "game_ended", endData)
+
+	// Encoded before the hand-back, delivered after it so that a rematch
+	// offer sent straight back finds the game over
+	ended := a.record(0, &protocol.GameEnded{Winner: winner, GameState: game})
 
 	// Analytics
 	if gm.analyticsService != nil {
 		gm.analyticsService.TrackEvent("game_ended", map[string]interface{}{
-			"gameId":   game.ID,
-			"winner":   winner,
-			"duration": game.GetDuration(),
-			"moves":    len(game.Moves),
-			"gameType": map[bool]string{true: "bot", false: "pvp"}[game.IsBot],
+			"gameId":      game.ID,
+			"winner":      winner,
+			"duration":    game.GetDuration(),
+			"moves":       len(game.Moves),
+			"gameType":    gameType(game),
+			"difficulty":  game.Difficulty,
+			"hintsUsed":   game.HintsUsed,
+			"abandonedBy": game.AbandonedBy,
+			"timedOut":    game.TimedOut,
+			"endReason":   game.EndReason,
+			"takebacks":   game.Takebacks,
 		})
 	}
 
 	// Save to database
+	results := playerResults(game)
 	go func() {
 		if gm.dbService != nil {
 			gameData := services.GameData{
-				ID:       game.ID,
-				Player1:  game.Player1.Username,
-				Player2:  game.Player2.Username,
-				Winner:   winner,
-				Duration: game.GetDuration(),
-				Moves:    len(game.Moves),
-				IsBot:    game.IsBot,
+				ID:            game.ID,
+				Player1:       game.Player1.Username,
+				Player2:       game.Player2.Username,
+				Winner:        winner,
+				Duration:      game.GetDuration(),
+				Moves:         len(game.Moves),
+				IsBot:         game.IsBot,
+				BotDifficulty: game.Difficulty,
+				BotSeat:       botSeat(game),
+				EndReason:     game.EndReason,
+				MoveHistory:   game.Moves,
+				CreatedAt:     game.CreatedAt,
+				Results:       results,
 			}
-			gm.dbService.SaveGame(gameData)
-
-			if winner != nil {
-				winnerName := game.Player1.Username
-				if *winner == 2 {
-					winnerName = game.Player2.Username
-				}
-				gm.dbService.UpdatePlayerStats(winnerName, true)
+			if err := gm.dbService.SaveGame(gameData); err != nil {
+				log.Printf("Failed to save game %s: %v", game.ID, err)
 			}
 		}
 	}()
 
-	// Cleanup after 30 seconds
-	go func() {
-		time.Sleep(30 * time.Second)
-		gm.mu.Lock()
-		delete(gm.games, game.ID)
-		gm.mu.Unlock()
-	}()
+	gm.gameEnded(a)
+	if ended != nil {
+		a.deliver(0, ended)
+	}
 }
 
-func (gm *GameManager) cleanup() {
-	gm.mu.Lock()
-	defer gm.mu.Unlock()
+// playerResults reports the outcome for each human in a finished game; bots
+// get no stats of their own.
+func playerResults(game *models.Game) []services.PlayerResult {
+	var results []services.PlayerResult
+	for num, player := range []*models.Player{game.Player1, game.Player2} {
+		if player == nil || player.IsBot {
+			continue
+		}
 
-	now := time.Now()
-	for username, info := range gm.disconnected {
-		if now.Sub(info.Time).Seconds() > 30 {
-			game, exists := gm.games[info.GameID]
-			if exists && game.Status == "playing" {
-				winner := 2
-				if info.PlayerNum == 2 {
-					winner = 1
-				}
-				gm.endGame(game, &winner)
+		result := services.PlayerResult{
+			Username:  player.Username,
+			Result:    services.ResultDraw,
+			VsBot:     game.IsBot,
+			Abandoned: game.AbandonedBy == num+1,
+			// Hints and takebacks would make vs-bot ratings meaningless
+			Rated: game.Rated || (game.IsBot && game.HintsUsed == 0 && game.Takebacks == 0),
+		}
+		if game.IsBot {
+			result.OpponentRating = ParseDifficulty(game.Difficulty).Rating()
+		} else if num == 0 {
+			result.Opponent = game.Player2.Username
+		} else {
+			result.Opponent = game.Player1.Username
+		}
+		if game.Winner != nil {
+			result.Result = services.ResultLoss
+			if *game.Winner == num+1 {
+				result.Result = services.ResultWin
 			}
-			delete(gm.disconnected, username)
 		}
+		results = append(results, result)
 	}
+	return results
 }
 
-func (gm *GameManager) broadcastToGame(gameID string, msgType string, data interface{}) {
-	for conn, player := range gm.connections {
-		if player.GameID == gameID {
-			gm.sendMessage(conn, msgType, data)
+// gameType labels a game for analytics: bot, private or pvp.
+func gameType(game *models.Game) string {
+	switch {
+	case game.IsBot:
+		return "bot"
+	case game.Private:
+		return "private"
+	default:
+		return "pvp"
+	}
+}
+
+func parseUsername(username string) (string, bool) {
+	username = strings.TrimSpace(username)
+	return username, len(username) >= 2
+}
+
+// applyResults moves the in-memory ratings of players still connected to a
+// finished game the way SaveGame moves the stored ones, so a rematch shows
+// the new ratings without a round trip to storage.
+func (gm *GameManager) applyResults(game *models.Game) {
+	players := gm.gamePlayers(game.ID)
+	score := func(seat int) float64 {
+		switch {
+		case game.Winner == nil:
+			return rating.Draw
+		case *game.Winner == seat:
+			return rating.Win
+		default:
+			return rating.Loss
 		}
 	}
+
+	if game.IsBot {
+		seat := 3 - botSeat(game)
+		if player := players[seat]; player != nil && game.HintsUsed == 0 && game.Takebacks == 0 {
+			opponent := ParseDifficulty(game.Difficulty).Rating()
+			player.BotRating = player.BotRating.Update(rating.Result{Opponent: opponent, Score: score(seat)})
+		}
+		return
+	}
+	if !game.Rated || players[1] == nil || players[2] == nil {
+		return
+	}
+	before1, before2 := players[1].Rating, players[2].Rating
+	players[1].Rating = before1.Update(rating.Result{Opponent: before2, Score: score(1)})
+	players[2].Rating = before2.Update(rating.Result{Opponent: before1, Score: score(2)})
 }
 
-func (gm *GameManager) notifyDisconnect(game *models.Game, player *Player) {
-	for conn, p := range gm.connections {
-		if p.GameID == game.ID && p != player {
-			gm.sendMessage(conn, "player_disconnected", map[string]interface{}{
-				"player":        player.Username,
-				"reconnectTime": 30,
-			})
+// gamePlayers finds the connected players of a game, indexed by seat.
+func (gm *GameManager) gamePlayers(gameID string) [3]*Player {
+	var players [3]*Player
+	for _, player := range gm.connections {
+		if player.GameID == gameID && player.PlayerNum > 0 {
+			players[player.PlayerNum] = player
 		}
 	}
+	return players
 }
 
-func (gm *GameManager) notifyReconnect(game *models.Game, player *Player) {
-	for conn, p := range gm.connections {
-		if p.GameID == game.ID && p != player {
-			gm.sendMessage(conn, "player_reconnected", map[string]interface{}{
-				"player": player.Username,
-			})
+// loadRatings fetches a player's stored PvP and vs-bot ratings, defaulting
+// for new players or when storage is unavailable.
+func (gm *GameManager) loadRatings(username string) (rating.Rating, rating.Rating) {
+	pvp, bot := rating.Default(), rating.Default()
+	if gm.dbService == nil {
+		return pvp, bot
+	}
+
+	stats, err := gm.dbService.GetPlayerStats(username)
+	if err != nil {
+		log.Printf("Failed to load ratings for %s: %v", username, err)
+		return pvp, bot
+	}
+	if stats != nil {
+		pvp.Rating, pvp.Deviation = stats.Rating, stats.RatingDeviation
+		bot.Rating, bot.Deviation = stats.BotRating, stats.BotRatingDeviation
+	}
+	return pvp, bot
+}
+
+func ratedPlayer(player *models.Player, r rating.Rating) *models.Player {
+	player.Rating = int(math.Round(r.Rating))
+	player.Provisional = r.Provisional()
+	return player
+}
+
+// newBot allocates a per-game engine, shrinking its transposition table when
+// the configured memory limit for all bot games would be exceeded.
+func (gm *GameManager) newBot(difficulty Difficulty) *Bot {
+	sizeMB := gm.cfg.BotTableSizeMB
+	if sizeMB <= 0 {
+		sizeMB = DefaultTransTableSizeMB
+	}
+
+	if gm.cfg.BotMemoryLimitMB > 0 {
+		var usedBytes int64
+		for _, bot := range gm.engines() {
+			usedBytes += bot.TableStats().Bytes
+		}
+		remainingMB := gm.cfg.BotMemoryLimitMB - int(usedBytes/(1024*1024))
+		if remainingMB < sizeMB {
+			log.Printf("Bot memory limit reached (%d MB), using reduced table", gm.cfg.BotMemoryLimitMB)
+			sizeMB = remainingMB
+			if sizeMB < 1 {
+				sizeMB = 1
+			}
 		}
 	}
+
+	bot := NewBotWithDifficulty(difficulty, sizeMB)
+	if settings, ok := gm.difficulties[difficulty]; ok {
+		bot.settings = settings
+	}
+	if bot.settings.UseSolver && gm.book != nil {
+		bot.UseOpeningBook(gm.book)
+	}
+	return bot
 }
 
-func (gm *GameManager) sendMessage(conn *websocket.Conn, msgType string, data interface{}) {
-	message := map[string]interface{}{
-		"type": msgType,
-		"data": data,
+func (gm *GameManager) BotStats() BotStats {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	stats := BotStats{
+		ActiveBots:    len(gm.bots),
+		AnalysisBots:  len(gm.analysisEngines),
+		TableSizeMB:   gm.cfg.BotTableSizeMB,
+		MemoryLimitMB: gm.cfg.BotMemoryLimitMB,
 	}
-	if err := conn.WriteJSON(message); err != nil {
-		log.Printf("Failed to send message: %v", err)
+	for _, bot := range gm.engines() {
+		stats.Tables = stats.Tables.Add(bot.TableStats())
 	}
+	return stats
 }
 
-func (gm *GameManager) sendError(conn *websocket.Conn, message string) {
-	gm.sendMessage(conn, "error", map[string]string{"message": message})
-}
\ No newline at end of file
+// engines lists every bot holding tables: those playing and those kept for
+// analysis. Callers hold gm.mu.
+func (gm *GameManager) engines() []*Bot {
+	engines := make([]*Bot, 0, len(gm.bots)+len(gm.analysisEngines))
+	for _, bot := range gm.bots {
+		engines = append(engines, bot)
+	}
+	return append(engines, gm.analysisEngines...)
+}
+
+func (gm *GameManager) cleanup() {
+	type abandoned struct {
+		game *gameActor
+		seat int
+	}
+	var games []abandoned
+
+	gm.mu.Lock()
+	now := time.Now()
+	for playerID, info := range gm.disconnected {
+		if now.Sub(info.Time).Seconds() > 30 {
+			if a, exists := gm.games[info.GameID]; exists && !a.ended {
+				games = append(games, abandoned{game: a, seat: info.PlayerNum})
+			}
+			delete(gm.disconnected, playerID)
+		}
+	}
+	gm.mu.Unlock()
+
+	// Ending a game takes gm.mu
+	for _, g := range games {
+		a, seat := g.game, g.seat
+		a.do(func() { a.abandon(seat) })
+	}
+}
+
+// broadcastToGame sends a message to everyone following a game without
+// logging it; game events go through publish instead.
+func (gm *GameManager) broadcastToGame(gameID string, msg protocol.Message) {
+	message, err := protocol.Encode(msg)
+	if err != nil {
+		log.Printf("Failed to encode %s: %v", msg.MessageType(), err)
+		return
+	}
+	gm.broadcastEncoded(gameID, message)
+}
+
+func (gm *GameManager) broadcastEncoded(gameID string, message []byte) {
+	for conn, player := range gm.connections {
+		if player.GameID == gameID {
+			gm.write(conn, message)
+		}
+	}
+
+	for _, conn := range gm.spectators.watchers(gameID) {
+		gm.write(conn, message)
+	}
+}
+
+func (a *gameActor) notifyDisconnect(seat int) {
+	a.publish(3-seat, &protocol.PlayerDisconnected{
+		Player:        a.username(seat),
+		ReconnectTime: 30,
+		Clock:         a.game.ClockState(time.Now()),
+	})
+}
+
+func (a *gameActor) notifyReconnect(seat int) {
+	a.publish(3-seat, &protocol.PlayerReconnected{
+		Player: a.username(seat),
+		Clock:  a.game.ClockState(time.Now()),
+	})
+}
+
+// Send delivers a message outside of any game event, such as replies to
+// the protocol handshake. It only queues, so gm.mu isn't needed.
+func (gm *GameManager) Send(conn *websocket.Conn, msg protocol.Message) {
+	gm.sendMessage(conn, msg)
+}
+
+func (gm *GameManager) sendMessage(conn *websocket.Conn, msg protocol.Message) {
+	message, err := protocol.Encode(msg)
+	if err != nil {
+		log.Printf("Failed to encode %s: %v", msg.MessageType(), err)
+		return
+	}
+	gm.write(conn, message)
+}
+
+// write queues an encoded message for conn's writer. Connections that have
+// gone or been dropped for falling behind are skipped.
+func (gm *GameManager) write(conn *websocket.Conn, message []byte) {
+	gm.clients.send(conn, message)
+}
+
+// sendError replies to a failed request with a protocol error code.
+func (gm *GameManager) sendError(conn *websocket.Conn, requestID, code, message string) {
+	gm.sendMessage(conn, &protocol.Error{Code: code, Message: message, RequestID: requestID})
+}
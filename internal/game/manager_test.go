@@ -0,0 +1,285 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"emitrr-4-in-a-row/internal/config"
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+	"emitrr-4-in-a-row/internal/rating"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestManager runs without storage or analytics, with quick bots and
+// untimed games.
+func newTestManager(t *testing.T) *GameManager {
+	t.Helper()
+	return NewGameManager(&config.Config{
+		BotTableSizeMB:    1,
+		BotMemoryLimitMB:  512,
+		OpeningBookPath:   "testdata/no-opening-book.bin",
+		BotThinkTime:      "beginner=20,casual=20,strong=50,perfect=50",
+		BotMoveDelay:      "beginner=0,casual=0,strong=0,perfect=0",
+		TimeControl:       "none",
+		EventLogSize:      100,
+		RoomExpiryMinutes: 10,
+	}, nil, nil)
+}
+
+// connect opens a real WebSocket to gm and returns both ends: the server's,
+// which gm knows the player by, and the client's, which receives events.
+func connect(t *testing.T, gm *GameManager) (server, client *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		gm.Connect(conn)
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return <-conns, client
+}
+
+// startPvPGame connects two players and starts a game between them. Both
+// ends of each player's connection come back indexed by seat.
+func startPvPGame(t *testing.T, gm *GameManager, opts gameOptions) (gameID string, servers, clients [3]*websocket.Conn) {
+	t.Helper()
+	var players [3]*Player
+	for seat := 1; seat <= 2; seat++ {
+		servers[seat], clients[seat] = connect(t, gm)
+		players[seat] = &Player{ID: newPlayerID(), Username: fmt.Sprintf("player%d", seat), Conn: servers[seat]}
+	}
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	for seat := 1; seat <= 2; seat++ {
+		gm.connections[servers[seat]] = players[seat]
+	}
+	return gm.startPvPGame(players[1], players[2], opts).ID, servers, clients
+}
+
+// readUntil reads events off client until one of type msgType arrives and
+// returns its data.
+func readUntil(t *testing.T, client *websocket.Conn, msgType string) json.RawMessage {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var envelope struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := client.ReadJSON(&envelope); err != nil {
+			t.Fatalf("waiting for %s: %v", msgType, err)
+		}
+		if envelope.Type == msgType {
+			return envelope.Data
+		}
+	}
+}
+
+// playBotGame starts a bot game for a new player and plays random legal
+// moves for them until the game ends.
+func playBotGame(t *testing.T, gm *GameManager, username string, difficulty Difficulty, humanSeat int) {
+	server, client := connect(t, gm)
+
+	gm.mu.Lock()
+	player := &Player{
+		ID:         newPlayerID(),
+		Username:   username,
+		Conn:       server,
+		Difficulty: difficulty,
+		Rating:     rating.Default(),
+		BotRating:  rating.Default(),
+	}
+	gm.connections[server] = player
+	gm.startBotGame(player, gameOptions{humanSeat: humanSeat})
+	gm.mu.Unlock()
+
+	seat := 0
+	client.SetReadDeadline(time.Now().Add(time.Minute))
+	for {
+		var envelope struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := client.ReadJSON(&envelope); err != nil {
+			t.Errorf("%s: %v", username, err)
+			return
+		}
+
+		var event struct {
+			GameState  *models.Game `json:"gameState"`
+			YourPlayer int          `json:"yourPlayer"`
+		}
+		json.Unmarshal(envelope.Data, &event)
+
+		switch envelope.Type {
+		case "game_started":
+			seat = event.YourPlayer
+		case "move_made":
+		case "game_ended":
+			if event.GameState.Status != "finished" {
+				t.Errorf("%s: game ended with status %s", username, event.GameState.Status)
+			}
+			return
+		default:
+			continue
+		}
+
+		game := event.GameState
+		if game.Status != "playing" || game.CurrentPlayer != seat {
+			continue
+		}
+		pos := PositionFromBoard(game.Board)
+		moves := pos.ValidMoves()
+		column := moves[rand.Intn(len(moves))]
+		_, perr := gm.HandlePlayerMove(server, "", protocol.MakeMove{GameID: game.ID, Column: &column, Seq: &game.Seq})
+		if perr != nil {
+			t.Errorf("%s: move %d: %s %s", username, column, perr.Code, perr.Message)
+			return
+		}
+	}
+}
+
+func TestConcurrentBotGames(t *testing.T) {
+	gm := newTestManager(t)
+
+	const games = 32
+	var wg sync.WaitGroup
+	for i := 0; i < games; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			difficulty := Difficulties()[i%len(Difficulties())]
+			playBotGame(t, gm, fmt.Sprintf("player%d", i), difficulty, 1+i%2)
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := gm.BotStats(); stats.ActiveBots != 0 {
+		t.Errorf("%d bots still active after every game ended", stats.ActiveBots)
+	}
+}
+
+func TestTransTableConcurrentAccess(t *testing.T) {
+	table := NewTransTable(1)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(worker)))
+			for i := 0; i < 20000; i++ {
+				key := rng.Uint64() % 4096
+				if i%3 == 0 {
+					table.Put(key, TransEntry{Score: float64(key), Depth: int(key % 10)})
+				} else if entry, ok := table.Get(key); ok && entry.Score != float64(key) {
+					t.Errorf("key %d holds score %v", key, entry.Score)
+					return
+				}
+				if i%5000 == 0 {
+					table.NewSearch()
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	if stats := table.Stats(); stats.Entries == 0 || stats.Entries > int64(stats.Capacity) {
+		t.Errorf("%d entries in a table of %d slots", stats.Entries, stats.Capacity)
+	}
+}
+
+func TestJoinWhileBusy(t *testing.T) {
+	gm := newTestManager(t)
+
+	queued, _ := connect(t, gm)
+	if perr := gm.HandlePlayerJoin(queued, protocol.JoinGame{Username: "alice"}); perr != nil {
+		t.Fatalf("first join: %v", perr)
+	}
+	if perr := gm.HandlePlayerJoin(queued, protocol.JoinGame{Username: "alice"}); perr == nil || perr.Code != protocol.ErrConflict {
+		t.Errorf("joining the queue twice: %v, want %s", perr, protocol.ErrConflict)
+	}
+
+	playing, _ := connect(t, gm)
+	gm.mu.Lock()
+	player := &Player{ID: newPlayerID(), Username: "bob", Conn: playing, Difficulty: DifficultyBeginner}
+	gm.connections[playing] = player
+	game := gm.startBotGame(player, gameOptions{})
+	gm.mu.Unlock()
+
+	if perr := gm.HandlePlayerJoin(playing, protocol.JoinGame{Username: "bob"}); perr == nil || perr.Code != protocol.ErrConflict {
+		t.Errorf("joining during a game: %v, want %s", perr, protocol.ErrConflict)
+	}
+
+	if perr := gm.HandleResign(playing, protocol.Resign{GameID: game.ID}); perr != nil {
+		t.Fatalf("resign: %v", perr)
+	}
+	if perr := gm.HandlePlayerJoin(playing, protocol.JoinGame{Username: "bob"}); perr != nil {
+		t.Errorf("joining after the game ended: %v", perr)
+	}
+}
+
+func TestMoveRetries(t *testing.T) {
+	gm := newTestManager(t)
+	gameID, servers, _ := startPvPGame(t, gm, gameOptions{})
+	gm.mu.RLock()
+	a := gm.games[gameID]
+	gm.mu.RUnlock()
+	moves := func() (n int) {
+		gm.inGame(a, func() { n = len(a.game.Moves) })
+		return n
+	}
+
+	// A retry of the same request is acknowledged again but only played once
+	column, seq := 3, 0
+	move := protocol.MakeMove{GameID: gameID, Column: &column, Seq: &seq}
+	first, perr := gm.HandlePlayerMove(servers[1], "m1", move)
+	if perr != nil {
+		t.Fatalf("move: %v", perr)
+	}
+	retry, perr := gm.HandlePlayerMove(servers[1], "m1", move)
+	if perr != nil {
+		t.Fatalf("retry: %v", perr)
+	}
+	if first.Duplicate || first.Seq != 1 || !retry.Duplicate || retry.Seq != 1 {
+		t.Errorf("acks %+v and %+v, want seq 1 with the retry marked duplicate", first, retry)
+	}
+	if n := moves(); n != 1 {
+		t.Errorf("%d moves played, want 1", n)
+	}
+
+	// Request IDs are the client's, so the opponent may reuse one
+	if ack, perr := gm.HandlePlayerMove(servers[2], "m1", protocol.MakeMove{GameID: gameID, Column: &column}); perr != nil || ack.Duplicate {
+		t.Fatalf("opponent's move with the same request ID: %+v, %v", ack, perr)
+	}
+
+	// A move made against a position the game has moved on from is refused
+	old := 1
+	if _, perr := gm.HandlePlayerMove(servers[1], "m2", protocol.MakeMove{GameID: gameID, Column: &column, Seq: &old}); perr == nil || perr.Code != protocol.ErrStaleSeq {
+		t.Errorf("move at seq 1 with the game at 2: %v, want %s", perr, protocol.ErrStaleSeq)
+	}
+	if n := moves(); n != 2 {
+		t.Errorf("%d moves played, want 2", n)
+	}
+}
@@ -0,0 +1,200 @@
+package game
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"emitrr-4-in-a-row/internal/protocol"
+)
+
+const matchmakingInterval = 500 * time.Millisecond
+
+type queueEntry struct {
+	player   *Player
+	joinedAt time.Time
+}
+
+// matchQueue holds players looking for a PvP opponent plus running totals
+// for the stats endpoint. It is guarded by gm.mu.
+type matchQueue struct {
+	entries []*queueEntry
+
+	matches        int
+	botFallbacks   int
+	totalMatchWait time.Duration
+	totalGap       float64
+	totalQuality   float64
+}
+
+type MatchmakingStats struct {
+	QueueSize       int     `json:"queueSize"`
+	AverageWait     float64 `json:"averageWait"` // seconds, players waiting right now
+	MatchesMade     int     `json:"matchesMade"`
+	BotFallbacks    int     `json:"botFallbacks"`
+	AvgMatchWait    float64 `json:"avgMatchWait"` // seconds until paired with a person
+	AvgRatingGap    float64 `json:"avgRatingGap"`
+	AvgMatchQuality float64 `json:"avgMatchQuality"` // 1 = evenly matched, 0 = foregone conclusion
+}
+
+func (gm *GameManager) startMatchmaking() {
+	go func() {
+		ticker := time.NewTicker(matchmakingInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			gm.mu.Lock()
+			gm.matchWaitingPlayers(time.Now())
+			gm.mu.Unlock()
+		}
+	}()
+}
+
+// enqueue adds a player to the matchmaking queue and tries to pair them
+// straight away. Callers hold gm.mu.
+func (gm *GameManager) enqueue(player *Player) {
+	gm.queue.entries = append(gm.queue.entries, &queueEntry{player: player, joinedAt: time.Now()})
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("matchmaking_joined", map[string]interface{}{
+			"player":    player.Username,
+			"rating":    player.Rating.Rating,
+			"queueSize": len(gm.queue.entries),
+		})
+	}
+
+	gm.matchWaitingPlayers(time.Now())
+	if player.GameID == "" {
+		gm.sendMessage(player.Conn, &protocol.WaitingForOpponent{
+			Rating:     math.Round(player.Rating.Rating),
+			BotTimeout: gm.cfg.MatchmakingBotTimeout,
+		})
+	}
+}
+
+// dequeue removes a player who left before being matched. Callers hold gm.mu.
+func (gm *GameManager) dequeue(player *Player) bool {
+	for i, entry := range gm.queue.entries {
+		if entry.player == player {
+			gm.queue.entries = append(gm.queue.entries[:i], gm.queue.entries[i+1:]...)
+			if gm.analyticsService != nil {
+				gm.analyticsService.TrackEvent("matchmaking_left", map[string]interface{}{
+					"player": player.Username,
+					"wait":   time.Since(entry.joinedAt).Seconds(),
+				})
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// window is the rating gap an entry accepts, growing the longer it waits.
+func (gm *GameManager) window(entry *queueEntry, now time.Time) float64 {
+	window := float64(gm.cfg.MatchmakingWindow) + float64(gm.cfg.MatchmakingWindowGrowth)*now.Sub(entry.joinedAt).Seconds()
+	if gm.cfg.MatchmakingMaxWindow > 0 {
+		window = math.Min(window, float64(gm.cfg.MatchmakingMaxWindow))
+	}
+	return window
+}
+
+// matchWaitingPlayers pairs the longest-waiting players first, each with the
+// closest-rated opponent inside the wider of the two windows, then sends
+// anyone past the timeout to a bot. Callers hold gm.mu.
+func (gm *GameManager) matchWaitingPlayers(now time.Time) {
+	matched := make(map[*queueEntry]bool)
+	for i, entry := range gm.queue.entries {
+		if matched[entry] {
+			continue
+		}
+
+		var best *queueEntry
+		bestGap := math.Inf(1)
+		for _, candidate := range gm.queue.entries[i+1:] {
+			if matched[candidate] {
+				continue
+			}
+			gap := math.Abs(entry.player.Rating.Rating - candidate.player.Rating.Rating)
+			if gap <= math.Max(gm.window(entry, now), gm.window(candidate, now)) && gap < bestGap {
+				best, bestGap = candidate, gap
+			}
+		}
+
+		if best != nil {
+			matched[entry], matched[best] = true, true
+			gm.recordMatch(entry, best, now)
+			gm.startPvPGame(entry.player, best.player, gameOptions{rated: true, timeControl: gm.timeControl})
+		}
+	}
+
+	timeout := time.Duration(gm.cfg.MatchmakingBotTimeout) * time.Second
+	remaining := gm.queue.entries[:0]
+	for _, entry := range gm.queue.entries {
+		switch {
+		case matched[entry]:
+		case now.Sub(entry.joinedAt) >= timeout:
+			gm.queue.botFallbacks++
+			if gm.analyticsService != nil {
+				gm.analyticsService.TrackEvent("matchmaking_timeout", map[string]interface{}{
+					"player": entry.player.Username,
+					"rating": entry.player.Rating.Rating,
+					"wait":   now.Sub(entry.joinedAt).Seconds(),
+				})
+			}
+			gm.startBotGame(entry.player, gameOptions{timeControl: gm.timeControl})
+		default:
+			remaining = append(remaining, entry)
+		}
+	}
+	gm.queue.entries = remaining
+}
+
+func (gm *GameManager) recordMatch(first, second *queueEntry, now time.Time) {
+	gap := math.Abs(first.player.Rating.Rating - second.player.Rating.Rating)
+	quality := 1 - 2*math.Abs(first.player.Rating.ExpectedScore(second.player.Rating)-0.5)
+
+	gm.queue.matches++
+	gm.queue.totalMatchWait += now.Sub(first.joinedAt) + now.Sub(second.joinedAt)
+	gm.queue.totalGap += gap
+	gm.queue.totalQuality += quality
+
+	log.Printf("Matched %s (%.0f) with %s (%.0f), gap %.0f", first.player.Username, first.player.Rating.Rating,
+		second.player.Username, second.player.Rating.Rating, gap)
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("match_found", map[string]interface{}{
+			"player1":    first.player.Username,
+			"player2":    second.player.Username,
+			"ratingGap":  gap,
+			"quality":    quality,
+			"waitFirst":  now.Sub(first.joinedAt).Seconds(),
+			"waitSecond": now.Sub(second.joinedAt).Seconds(),
+		})
+	}
+}
+
+func (gm *GameManager) MatchmakingStats() MatchmakingStats {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	stats := MatchmakingStats{
+		QueueSize:    len(gm.queue.entries),
+		MatchesMade:  gm.queue.matches,
+		BotFallbacks: gm.queue.botFallbacks,
+	}
+
+	now := time.Now()
+	if len(gm.queue.entries) > 0 {
+		var total time.Duration
+		for _, entry := range gm.queue.entries {
+			total += now.Sub(entry.joinedAt)
+		}
+		stats.AverageWait = total.Seconds() / float64(len(gm.queue.entries))
+	}
+	if gm.queue.matches > 0 {
+		matches := float64(gm.queue.matches)
+		stats.AvgMatchWait = gm.queue.totalMatchWait.Seconds() / (2 * matches)
+		stats.AvgRatingGap = gm.queue.totalGap / matches
+		stats.AvgMatchQuality = gm.queue.totalQuality / matches
+	}
+	return stats
+}
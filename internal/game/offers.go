@@ -0,0 +1,256 @@
+package game
+
+import (
+	"log"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+func (gm *GameManager) HandleResign(conn *websocket.Conn, msg protocol.Resign) *protocol.Error {
+	a, seat, err := gm.playerGame(conn, msg.GameID)
+	if err != nil {
+		return err
+	}
+
+	gm.inGame(a, func() {
+		if err = a.playing(); err != nil {
+			return
+		}
+		winner := 3 - seat
+		a.game.EndReason = models.EndResign
+		a.endGame(&winner)
+	})
+	return err
+}
+
+func (gm *GameManager) HandleOfferDraw(conn *websocket.Conn, msg protocol.OfferDraw) *protocol.Error {
+	a, seat, err := gm.playerGame(conn, msg.GameID)
+	if err != nil {
+		return err
+	}
+
+	gm.inGame(a, func() {
+		if err = a.playing(); err == nil {
+			err = a.offerDraw(conn, seat)
+		}
+	})
+	return err
+}
+
+func (a *gameActor) offerDraw(conn *websocket.Conn, seat int) *protocol.Error {
+	game := a.game
+
+	// The bot plays every game out
+	if game.IsBot {
+		a.gm.sendMessage(conn, &protocol.DrawDeclined{GameID: game.ID, Player: "AI Bot"})
+		return nil
+	}
+
+	switch a.drawOffer {
+	case seat:
+		return protocol.NewError(protocol.ErrConflict, "Draw already offered")
+	case 3 - seat:
+		// Both offered, so both agree
+		a.drawOffer = 0
+		game.EndReason = models.EndDrawAgreed
+		a.endGame(nil)
+		return nil
+	}
+
+	if a.conns[3-seat] == nil {
+		return protocol.NewError(protocol.ErrBusy, "Opponent is not connected")
+	}
+	a.drawOffer = seat
+	a.publish(3-seat, &protocol.DrawOffered{GameID: game.ID, From: a.username(seat)})
+
+	if a.gm.analyticsService != nil {
+		a.gm.analyticsService.TrackEvent("draw_offered", map[string]interface{}{
+			"gameId": game.ID,
+			"player": a.username(seat),
+			"moves":  len(game.Moves),
+		})
+	}
+	return nil
+}
+
+func (gm *GameManager) HandleRespondDraw(conn *websocket.Conn, msg protocol.RespondDraw) *protocol.Error {
+	a, seat, err := gm.playerGame(conn, msg.GameID)
+	if err != nil {
+		return err
+	}
+
+	gm.inGame(a, func() {
+		if err = a.playing(); err == nil {
+			err = a.respondDraw(seat, msg.Accept)
+		}
+	})
+	return err
+}
+
+func (a *gameActor) respondDraw(seat int, accept bool) *protocol.Error {
+	from := a.drawOffer
+	if from == 0 || from == seat {
+		return protocol.NewError(protocol.ErrNotFound, "No draw offer to answer")
+	}
+	a.drawOffer = 0
+
+	if accept {
+		a.game.EndReason = models.EndDrawAgreed
+		a.endGame(nil)
+		return nil
+	}
+	a.publish(from, &protocol.DrawDeclined{GameID: a.game.ID, Player: a.username(seat)})
+	return nil
+}
+
+func (gm *GameManager) HandleRequestTakeback(conn *websocket.Conn, msg protocol.RequestTakeback) *protocol.Error {
+	a, seat, err := gm.playerGame(conn, msg.GameID)
+	if err != nil {
+		return err
+	}
+
+	gm.inGame(a, func() {
+		if err = a.playing(); err == nil {
+			err = a.requestTakeback(seat)
+		}
+	})
+	return err
+}
+
+func (a *gameActor) requestTakeback(seat int) *protocol.Error {
+	game := a.game
+	if takebackMoves(game, seat) == 0 {
+		return protocol.NewError(protocol.ErrInvalidMove, "Nothing to take back")
+	}
+
+	// The bot always lets you
+	if game.IsBot {
+		a.takeBack(seat)
+		return nil
+	}
+
+	if a.takeback == seat {
+		return protocol.NewError(protocol.ErrConflict, "Takeback already requested")
+	}
+	if a.conns[3-seat] == nil {
+		return protocol.NewError(protocol.ErrBusy, "Opponent is not connected")
+	}
+	a.takeback = seat
+	a.publish(3-seat, &protocol.TakebackRequested{GameID: game.ID, From: a.username(seat)})
+	return nil
+}
+
+func (gm *GameManager) HandleRespondTakeback(conn *websocket.Conn, msg protocol.RespondTakeback) *protocol.Error {
+	a, seat, err := gm.playerGame(conn, msg.GameID)
+	if err != nil {
+		return err
+	}
+
+	gm.inGame(a, func() {
+		if err = a.playing(); err == nil {
+			err = a.respondTakeback(seat, msg.Accept)
+		}
+	})
+	return err
+}
+
+func (a *gameActor) respondTakeback(seat int, accept bool) *protocol.Error {
+	from := a.takeback
+	if from == 0 || from == seat {
+		return protocol.NewError(protocol.ErrNotFound, "No takeback request to answer")
+	}
+	a.takeback = 0
+
+	if a.conns[from] == nil {
+		return nil
+	}
+	if accept {
+		a.takeBack(from)
+		return nil
+	}
+	a.publish(from, &protocol.TakebackDeclined{GameID: a.game.ID, Player: a.username(seat)})
+	return nil
+}
+
+// takeBack undoes seat's last move, and the reply to it if there was one,
+// so it is their turn again.
+func (a *gameActor) takeBack(seat int) {
+	game := a.game
+	n := takebackMoves(game, seat)
+	for i := 0; i < n; i++ {
+		if _, err := game.UndoMove(); err != nil {
+			log.Printf("Takeback in game %s failed: %v", game.ID, err)
+			return
+		}
+	}
+	game.Takebacks++
+	a.clearOffers()
+	a.stopThinking()
+
+	a.publish(0, &protocol.Takeback{
+		Player:    seat,
+		Moves:     n,
+		Seq:       game.Seq,
+		GameState: game,
+		Clock:     game.ClockState(time.Now()),
+	})
+	a.scheduleClock()
+
+	if a.gm.analyticsService != nil {
+		a.gm.analyticsService.TrackEvent("takeback", map[string]interface{}{
+			"gameId": game.ID,
+			"player": a.username(seat),
+			"moves":  n,
+		})
+	}
+}
+
+// takebackMoves is how many moves have to go for it to be seat's turn again
+// just before their last move: 0 if they haven't moved yet.
+func takebackMoves(game *models.Game, seat int) int {
+	moves := game.Moves
+	switch {
+	case len(moves) >= 1 && moves[len(moves)-1].Player == seat:
+		return 1
+	case len(moves) >= 2 && moves[len(moves)-2].Player == seat:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// playerGame finds the game conn is playing as gameID and its seat in it.
+func (gm *GameManager) playerGame(conn *websocket.Conn, gameID string) (*gameActor, int, *protocol.Error) {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	player, exists := gm.connections[conn]
+	if !exists || player.GameID != gameID {
+		return nil, 0, protocol.NewError(protocol.ErrNotFound, "Player not found")
+	}
+
+	a, exists := gm.games[gameID]
+	if !exists || a.ended {
+		return nil, 0, protocol.NewError(protocol.ErrGameNotActive, "Game not active")
+	}
+	return a, player.PlayerNum, nil
+}
+
+// playing checks that the game is still going.
+func (a *gameActor) playing() *protocol.Error {
+	if a.game.Status != "playing" {
+		return protocol.NewError(protocol.ErrGameNotActive, "Game not active")
+	}
+	return nil
+}
+
+// clearOffers drops pending draw offers and takeback requests, which only
+// stand until the next move.
+func (a *gameActor) clearOffers() {
+	a.drawOffer = 0
+	a.takeback = 0
+}
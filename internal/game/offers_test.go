@@ -0,0 +1,87 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+)
+
+func TestTakeback(t *testing.T) {
+	gm := newTestManager(t)
+	gameID, servers, clients := startPvPGame(t, gm, gameOptions{})
+
+	for i, column := range []int{3, 4} {
+		column := column
+		if _, perr := gm.HandlePlayerMove(servers[1+i], "", protocol.MakeMove{GameID: gameID, Column: &column}); perr != nil {
+			t.Fatalf("move %d: %v", i+1, perr)
+		}
+	}
+
+	if perr := gm.HandleRespondTakeback(servers[2], protocol.RespondTakeback{GameID: gameID, Accept: true}); perr == nil || perr.Code != protocol.ErrNotFound {
+		t.Errorf("answering a takeback nobody asked for: %v, want %s", perr, protocol.ErrNotFound)
+	}
+	if perr := gm.HandleRequestTakeback(servers[1], protocol.RequestTakeback{GameID: gameID}); perr != nil {
+		t.Fatalf("request takeback: %v", perr)
+	}
+	if perr := gm.HandleRequestTakeback(servers[1], protocol.RequestTakeback{GameID: gameID}); perr == nil || perr.Code != protocol.ErrConflict {
+		t.Errorf("asking twice: %v, want %s", perr, protocol.ErrConflict)
+	}
+	readUntil(t, clients[2], "takeback_requested")
+	if perr := gm.HandleRespondTakeback(servers[2], protocol.RespondTakeback{GameID: gameID, Accept: true}); perr != nil {
+		t.Fatalf("accept takeback: %v", perr)
+	}
+
+	// Player 1's move and the reply to it both go, so it is their turn again
+	var takeback protocol.Takeback
+	if err := json.Unmarshal(readUntil(t, clients[1], "takeback"), &takeback); err != nil {
+		t.Fatalf("takeback: %v", err)
+	}
+	game := takeback.GameState
+	if takeback.Player != 1 || takeback.Moves != 2 || takeback.Seq != 4 {
+		t.Errorf("takeback by %d of %d moves at seq %d, want 1, 2, 4", takeback.Player, takeback.Moves, takeback.Seq)
+	}
+	if game.CurrentPlayer != 1 || len(game.Moves) != 0 || game.Board[5][3] != 0 || game.Board[5][4] != 0 || game.Takebacks != 1 {
+		t.Errorf("player %d to move on %v after %d takebacks, want player 1 on an empty board", game.CurrentPlayer, game.Board, game.Takebacks)
+	}
+}
+
+func TestDrawOffers(t *testing.T) {
+	gm := newTestManager(t)
+	gameID, servers, clients := startPvPGame(t, gm, gameOptions{})
+
+	if perr := gm.HandleOfferDraw(servers[1], protocol.OfferDraw{GameID: gameID}); perr != nil {
+		t.Fatalf("offer draw: %v", perr)
+	}
+	if perr := gm.HandleOfferDraw(servers[1], protocol.OfferDraw{GameID: gameID}); perr == nil || perr.Code != protocol.ErrConflict {
+		t.Errorf("offering twice: %v, want %s", perr, protocol.ErrConflict)
+	}
+	readUntil(t, clients[2], "draw_offered")
+	if perr := gm.HandleRespondDraw(servers[2], protocol.RespondDraw{GameID: gameID}); perr != nil {
+		t.Fatalf("decline draw: %v", perr)
+	}
+	readUntil(t, clients[1], "draw_declined")
+
+	// An offer only stands until the next move
+	gm.HandleOfferDraw(servers[2], protocol.OfferDraw{GameID: gameID})
+	column := 0
+	if _, perr := gm.HandlePlayerMove(servers[1], "", protocol.MakeMove{GameID: gameID, Column: &column}); perr != nil {
+		t.Fatalf("move: %v", perr)
+	}
+	if perr := gm.HandleRespondDraw(servers[1], protocol.RespondDraw{GameID: gameID, Accept: true}); perr == nil || perr.Code != protocol.ErrNotFound {
+		t.Errorf("accepting an offer made before a move: %v, want %s", perr, protocol.ErrNotFound)
+	}
+
+	gm.HandleOfferDraw(servers[2], protocol.OfferDraw{GameID: gameID})
+	if perr := gm.HandleRespondDraw(servers[1], protocol.RespondDraw{GameID: gameID, Accept: true}); perr != nil {
+		t.Fatalf("accept draw: %v", perr)
+	}
+	var ended protocol.GameEnded
+	if err := json.Unmarshal(readUntil(t, clients[2], "game_ended"), &ended); err != nil {
+		t.Fatalf("game_ended: %v", err)
+	}
+	if ended.Winner != nil || ended.GameState.EndReason != models.EndDrawAgreed {
+		t.Errorf("game ended with winner %v and reason %q, want an agreed draw", ended.Winner, ended.GameState.EndReason)
+	}
+}
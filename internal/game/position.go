@@ -0,0 +1,269 @@
+package game
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+const (
+	BoardWidth  = 7
+	BoardHeight = 6
+
+	// Each column uses BoardHeight+1 bits; the extra bit on top is always
+	// empty so shifts never carry from one column into the next.
+	columnBits = BoardHeight + 1
+	boardCells = BoardWidth * BoardHeight
+)
+
+var (
+	bottomMask = func() uint64 {
+		var mask uint64
+		for col := 0; col < BoardWidth; col++ {
+			mask |= 1 << (col * columnBits)
+		}
+		return mask
+	}()
+	fullBoardMask = bottomMask * ((1 << BoardHeight) - 1)
+
+	zobristKeys = func() [2][BoardWidth * columnBits]uint64 {
+		var keys [2][BoardWidth * columnBits]uint64
+		rng := rand.New(rand.NewSource(0x4c0ffee))
+		for player := range keys {
+			for cell := range keys[player] {
+				keys[player][cell] = rng.Uint64()
+			}
+		}
+		return keys
+	}()
+)
+
+// Position is a bitboard representation of a 7x6 board. boards[0] holds the
+// stones of player 1 and boards[1] those of player 2; bit col*7+row is set
+// for a stone in that column, counting rows from the bottom.
+type Position struct {
+	boards  [2]uint64
+	height  [BoardWidth]int // bit index of the next free cell in each column
+	moves   int
+	key     uint64
+	history []int
+}
+
+func NewPosition() *Position {
+	p := &Position{history: make([]int, 0, boardCells)}
+	for col := 0; col < BoardWidth; col++ {
+		p.height[col] = col * columnBits
+	}
+	return p
+}
+
+// PositionFromBoard converts a models.Game board (row 0 at the top) into a
+// Position. The side to move is derived from the number of stones.
+func PositionFromBoard(board [][]int) *Position {
+	p := NewPosition()
+	for col := 0; col < BoardWidth; col++ {
+		for row := BoardHeight - 1; row >= 0; row-- {
+			cell := board[row][col]
+			if cell != 1 && cell != 2 {
+				break
+			}
+			bit := p.height[col]
+			p.boards[cell-1] |= 1 << bit
+			p.key ^= zobristKeys[cell-1][bit]
+			p.height[col]++
+			p.moves++
+		}
+	}
+	return p
+}
+
+func (p *Position) Clone() *Position {
+	clone := *p
+	clone.history = append(make([]int, 0, boardCells), p.history...)
+	return &clone
+}
+
+func (p *Position) Moves() int {
+	return p.moves
+}
+
+// CurrentPlayer returns 1 or 2 for the side to move.
+func (p *Position) CurrentPlayer() int {
+	return p.moves%2 + 1
+}
+
+func (p *Position) Key() uint64 {
+	return p.key
+}
+
+func (p *Position) mask() uint64 {
+	return p.boards[0] | p.boards[1]
+}
+
+func (p *Position) current() uint64 {
+	return p.boards[p.moves%2]
+}
+
+func (p *Position) opponent() uint64 {
+	return p.boards[1-p.moves%2]
+}
+
+func (p *Position) CanPlay(col int) bool {
+	return col >= 0 && col < BoardWidth && p.height[col] < col*columnBits+BoardHeight
+}
+
+func (p *Position) IsFull() bool {
+	return p.moves == boardCells
+}
+
+func (p *Position) ValidMoves() []int {
+	moves := make([]int, 0, BoardWidth)
+	for col := 0; col < BoardWidth; col++ {
+		if p.CanPlay(col) {
+			moves = append(moves, col)
+		}
+	}
+	return moves
+}
+
+// Play drops a stone for the side to move. The caller must check CanPlay.
+func (p *Position) Play(col int) {
+	side := p.moves % 2
+	bit := p.height[col]
+	p.boards[side] |= 1 << bit
+	p.key ^= zobristKeys[side][bit]
+	p.height[col]++
+	p.moves++
+	p.history = append(p.history, col)
+}
+
+// Undo takes back the last move made with Play.
+func (p *Position) Undo() {
+	col := p.history[len(p.history)-1]
+	p.history = p.history[:len(p.history)-1]
+	p.moves--
+	p.height[col]--
+	side := p.moves % 2
+	bit := p.height[col]
+	p.boards[side] &^= 1 << bit
+	p.key ^= zobristKeys[side][bit]
+}
+
+// IsWinningMove reports whether the side to move wins by playing col.
+func (p *Position) IsWinningMove(col int) bool {
+	return winningCells(p.current(), p.mask())&p.playable()&columnMask(col) != 0
+}
+
+// HasWon reports whether player (1 or 2) has four in a row.
+func (p *Position) HasWon(player int) bool {
+	return hasAlignment(p.boards[player-1])
+}
+
+// playable returns the next free cell of every non-full column.
+func (p *Position) playable() uint64 {
+	return (p.mask() + bottomMask) & fullBoardMask
+}
+
+// threats returns the playable cells that would complete four for stones.
+func (p *Position) threats(stones uint64) uint64 {
+	return winningCells(stones, p.mask()) & p.playable()
+}
+
+func columnMask(col int) uint64 {
+	return ((1 << BoardHeight) - 1) << (col * columnBits)
+}
+
+func hasAlignment(stones uint64) bool {
+	// horizontal, vertical and both diagonals
+	for _, shift := range [4]uint{columnBits, 1, columnBits - 1, columnBits + 1} {
+		m := stones & (stones >> shift)
+		if m&(m>>(2*shift)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// winningCells returns every empty cell that would complete four in a row for
+// stones, whether or not it is currently playable.
+func winningCells(stones, mask uint64) uint64 {
+	// vertical
+	r := (stones << 1) & (stones << 2) & (stones << 3)
+
+	for _, shift := range [3]uint{columnBits, columnBits - 1, columnBits + 1} {
+		p := (stones << shift) & (stones << (2 * shift))
+		r |= p & (stones << (3 * shift))
+		r |= p & (stones >> shift)
+		p = (stones >> shift) & (stones >> (2 * shift))
+		r |= p & (stones << shift)
+		r |= p & (stones >> (3 * shift))
+	}
+
+	return r & (fullBoardMask ^ mask)
+}
+
+func popcount(x uint64) int {
+	return bits.OnesCount64(x)
+}
+
+// nonLosingMoves returns the playable cells that do not hand the opponent an
+// immediate win. It assumes the side to move cannot win right away.
+func (p *Position) nonLosingMoves() uint64 {
+	possible := p.playable()
+	opponentWins := winningCells(p.opponent(), p.mask())
+	forced := possible & opponentWins
+	if forced != 0 {
+		if forced&(forced-1) != 0 {
+			return 0 // two threats at once cannot both be blocked
+		}
+		possible = forced
+	}
+	// never play directly below an opponent winning cell
+	return possible &^ (opponentWins >> 1)
+}
+
+// orderByThreats returns the columns of the cells in moves sorted by how many
+// winning cells the move creates, center columns first on ties.
+func (p *Position) orderByThreats(moves uint64) []int {
+	var cols [BoardWidth]int
+	var scores [BoardWidth]int
+	n := 0
+
+	me := p.current()
+	mask := p.mask()
+	for _, col := range columnOrder {
+		move := moves & columnMask(col)
+		if move == 0 {
+			continue
+		}
+		score := popcount(winningCells(me|move, mask|move))
+
+		// insertion sort keeps the center order stable for equal scores
+		i := n
+		for ; i > 0 && scores[i-1] < score; i-- {
+			cols[i], scores[i] = cols[i-1], scores[i-1]
+		}
+		cols[i], scores[i] = col, score
+		n++
+	}
+	return cols[:n]
+}
+
+// canonicalKey identifies a position independently of the Zobrist table and
+// maps mirror-image positions to the same key.
+func (p *Position) canonicalKey() uint64 {
+	key := p.current() + p.mask() + bottomMask
+	mirrored := mirrorKey(key)
+	if mirrored < key {
+		return mirrored
+	}
+	return key
+}
+
+func mirrorKey(key uint64) uint64 {
+	var mirrored uint64
+	for col := 0; col < BoardWidth; col++ {
+		column := (key >> (col * columnBits)) & (1<<columnBits - 1)
+		mirrored |= column << ((BoardWidth - 1 - col) * columnBits)
+	}
+	return mirrored
+}
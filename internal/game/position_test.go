@@ -0,0 +1,104 @@
+package game
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+)
+
+// The bitboard engine replaced one that copied a [][]int board for every
+// move and scanned it for wins. Its figures on the same position, measured
+// before the rewrite (Xeon, go1.21):
+//
+//	play (board copy)   ~490 ns/op
+//	win check           ~48 ns/op
+//	minimax, depth 5    ~27,000 nodes/s
+//
+// boardPlay and models.Game.CheckWin below keep the first two measurable.
+
+var benchMoves = []int{3, 3, 2, 4, 2, 2, 4, 3}
+
+func positionAfter(moves ...int) *Position {
+	pos := NewPosition()
+	for _, col := range moves {
+		pos.Play(col)
+	}
+	return pos
+}
+
+// boardPlay is how the [][]int engine made a move: copy, then drop the disc.
+func boardPlay(board [][]int, col, player int) [][]int {
+	next := make([][]int, len(board))
+	for row := range board {
+		next[row] = make([]int, BoardWidth)
+		copy(next[row], board[row])
+	}
+	for row := BoardHeight - 1; row >= 0; row-- {
+		if next[row][col] == 0 {
+			next[row][col] = player
+			break
+		}
+	}
+	return next
+}
+
+func benchBoard() [][]int {
+	board := make([][]int, BoardHeight)
+	for row := range board {
+		board[row] = make([]int, BoardWidth)
+	}
+	for i, col := range benchMoves {
+		board = boardPlay(board, col, i%2+1)
+	}
+	return board
+}
+
+func BenchmarkPositionPlayUndo(b *testing.B) {
+	pos := positionAfter(benchMoves...)
+	for i := 0; i < b.N; i++ {
+		pos.Play(i % BoardWidth)
+		pos.Undo()
+	}
+}
+
+func BenchmarkBoardPlay(b *testing.B) {
+	board := benchBoard()
+	for i := 0; i < b.N; i++ {
+		boardPlay(board, i%BoardWidth, 1)
+	}
+}
+
+func BenchmarkPositionIsWinningMove(b *testing.B) {
+	pos := positionAfter(benchMoves...)
+	for i := 0; i < b.N; i++ {
+		pos.IsWinningMove(i % BoardWidth)
+	}
+}
+
+func BenchmarkBoardCheckWin(b *testing.B) {
+	game := &models.Game{Board: benchBoard()}
+	for i := 0; i < b.N; i++ {
+		game.CheckWin(3, i%BoardWidth, 1)
+	}
+}
+
+// BenchmarkNegamax searches the same position to the same depth as the
+// baseline figure above, starting from an empty table every time.
+func BenchmarkNegamax(b *testing.B) {
+	pos := positionAfter(benchMoves...)
+	var nodes uint64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		bot := NewBotWithDifficulty(DifficultyStrong, 1)
+		bot.ctx = context.Background()
+		bot.deadline = time.Now().Add(time.Hour)
+		b.StartTimer()
+
+		bot.negamax(pos, 5, math.Inf(-1), math.Inf(1))
+		nodes += bot.nodes
+	}
+	b.ReportMetric(float64(nodes)/b.Elapsed().Seconds(), "nodes/s")
+}
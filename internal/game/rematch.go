@@ -0,0 +1,229 @@
+package game
+
+import (
+	"log"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+const rematchTimeout = 30 * time.Second
+
+// rematchOffer is one player's offer to play the same opponent again. It
+// keeps the finished game itself because the game is dropped from gm.games
+// 30 seconds after it ends.
+type rematchOffer struct {
+	game  *models.Game
+	from  *Player
+	to    *Player
+	timer *time.Timer
+}
+
+func (gm *GameManager) HandleOfferRematch(conn *websocket.Conn, msg protocol.OfferRematch) *protocol.Error {
+	gameID := msg.GameID
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	player, game, err := gm.finishedGame(conn, gameID)
+	if err != nil {
+		return err
+	}
+
+	// The bot always says yes
+	if game.IsBot {
+		gm.startRematch(game, player, nil)
+		return nil
+	}
+
+	if offer, exists := gm.rematches[gameID]; exists {
+		if offer.from == player {
+			return protocol.NewError(protocol.ErrConflict, "Rematch already offered")
+		}
+		// Both asked at once
+		gm.acceptRematch(offer)
+		return nil
+	}
+
+	opponent := gm.gamePlayers(gameID)[3-player.PlayerNum]
+	if opponent == nil {
+		gm.sendMessage(conn, &protocol.RematchCancelled{GameID: gameID, Reason: "opponent_left"})
+		return nil
+	}
+
+	offer := &rematchOffer{game: game, from: player, to: opponent}
+	offer.timer = time.AfterFunc(rematchTimeout, func() { gm.expireRematch(offer) })
+	gm.rematches[gameID] = offer
+
+	gm.sendMessage(opponent.Conn, &protocol.RematchOffered{
+		GameID:  gameID,
+		From:    player.Username,
+		Timeout: int(rematchTimeout.Seconds()),
+	})
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("rematch_offered", map[string]interface{}{
+			"gameId": gameID,
+			"player": player.Username,
+		})
+	}
+	return nil
+}
+
+func (gm *GameManager) HandleAcceptRematch(conn *websocket.Conn, msg protocol.AcceptRematch) *protocol.Error {
+	gameID := msg.GameID
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	offer, exists := gm.rematches[gameID]
+	if !exists || offer.to.Conn != conn {
+		return protocol.NewError(protocol.ErrNotFound, "No rematch offer to accept")
+	}
+	gm.acceptRematch(offer)
+	return nil
+}
+
+func (gm *GameManager) HandleDeclineRematch(conn *websocket.Conn, msg protocol.DeclineRematch) *protocol.Error {
+	gameID := msg.GameID
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	offer, exists := gm.rematches[gameID]
+	if !exists || offer.to.Conn != conn {
+		return protocol.NewError(protocol.ErrNotFound, "No rematch offer to decline")
+	}
+	gm.closeRematch(offer)
+
+	gm.sendMessage(offer.from.Conn, &protocol.RematchDeclined{GameID: gameID, Player: offer.to.Username})
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("rematch_declined", map[string]interface{}{
+			"gameId": gameID,
+			"player": offer.to.Username,
+		})
+	}
+	return nil
+}
+
+// finishedGame checks that conn played gameID and that it is over. Callers
+// hold gm.mu.
+func (gm *GameManager) finishedGame(conn *websocket.Conn, gameID string) (*Player, *models.Game, *protocol.Error) {
+	player, exists := gm.connections[conn]
+	if !exists || player.GameID != gameID {
+		return nil, nil, protocol.NewError(protocol.ErrNotFound, "Player not found")
+	}
+
+	a, exists := gm.games[gameID]
+	if !exists {
+		return nil, nil, protocol.NewError(protocol.ErrNotFound, "Rematch no longer available")
+	}
+	if !a.ended {
+		return nil, nil, protocol.NewError(protocol.ErrConflict, "Game is still in progress")
+	}
+	return player, a.game, nil
+}
+
+func (gm *GameManager) acceptRematch(offer *rematchOffer) {
+	gm.closeRematch(offer)
+
+	// Either side may have moved on to another game on the same connection
+	for _, player := range []*Player{offer.from, offer.to} {
+		if gm.connections[player.Conn] != player || player.GameID != offer.game.ID {
+			gm.cancelRematch(offer, "opponent_left")
+			return
+		}
+	}
+	gm.startRematch(offer.game, offer.from, offer.to)
+}
+
+// startRematch starts the next game of a series with colours swapped.
+// opponent is nil for bot games.
+func (gm *GameManager) startRematch(previous *models.Game, player, opponent *Player) {
+	if previous.Series == nil {
+		previous.Series = models.NewSeries(previous.Player1.Username, previous.Player2.Username)
+		previous.Series.Record(previous)
+	}
+
+	opts := gameOptions{
+		private:     previous.Private,
+		rated:       previous.Rated,
+		humanSeat:   3 - player.PlayerNum,
+		series:      previous.Series,
+		timeControl: previous.TimeControl,
+	}
+
+	var game *models.Game
+	if opponent == nil {
+		player.Difficulty = ParseDifficulty(previous.Difficulty)
+		game = gm.startBotGame(player, opts)
+	} else if player.PlayerNum == 1 {
+		game = gm.startPvPGame(opponent, player, opts)
+	} else {
+		game = gm.startPvPGame(player, opponent, opts)
+	}
+
+	log.Printf("Rematch %s started from %s (game %d of series)", game.ID, previous.ID, game.Series.Games+1)
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("rematch_started", map[string]interface{}{
+			"gameId":         game.ID,
+			"previousGameId": previous.ID,
+			"gameType":       gameType(game),
+			"seriesGames":    game.Series.Games,
+			"seriesWins":     game.Series.Wins,
+			"seriesDraws":    game.Series.Draws,
+		})
+	}
+}
+
+func (gm *GameManager) expireRematch(offer *rematchOffer) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if gm.rematches[offer.game.ID] == offer {
+		gm.closeRematch(offer)
+		gm.cancelRematch(offer, "timeout")
+	}
+}
+
+// leaveRematch tells the opponent of a player who left after the game ended
+// that there will be no rematch. Callers hold gm.mu.
+func (gm *GameManager) leaveRematch(player *Player) {
+	if offer, exists := gm.rematches[player.GameID]; exists {
+		gm.closeRematch(offer)
+		gm.cancelRematch(offer, "opponent_left")
+		return
+	}
+
+	opponent := gm.gamePlayers(player.GameID)[3-player.PlayerNum]
+	if opponent != nil {
+		gm.sendMessage(opponent.Conn, &protocol.RematchCancelled{GameID: player.GameID, Reason: "opponent_left"})
+	}
+}
+
+// cancelRematch tells whoever is still around that the offer is off.
+// Callers hold gm.mu.
+func (gm *GameManager) cancelRematch(offer *rematchOffer, reason string) {
+	for _, player := range []*Player{offer.from, offer.to} {
+		if gm.connections[player.Conn] == player {
+			gm.sendMessage(player.Conn, &protocol.RematchCancelled{GameID: offer.game.ID, Reason: reason})
+		}
+	}
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("rematch_cancelled", map[string]interface{}{
+			"gameId": offer.game.ID,
+			"reason": reason,
+		})
+	}
+}
+
+func (gm *GameManager) closeRematch(offer *rematchOffer) {
+	offer.timer.Stop()
+	delete(gm.rematches, offer.game.ID)
+}
@@ -0,0 +1,156 @@
+package game
+
+import (
+	"log"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+	"emitrr-4-in-a-row/internal/services"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	maxReplayGap     = 3 * time.Second // long pauses are shortened before speed is applied
+	minReplayGap     = 100 * time.Millisecond
+	defaultReplayGap = time.Second
+)
+
+// GetGameRecord returns the full record of a game, live or finished.
+func (gm *GameManager) GetGameRecord(gameID string) (*services.GameRecord, error) {
+	gm.mu.RLock()
+	a, exists := gm.games[gameID]
+	gm.mu.RUnlock()
+	if exists {
+		var record *services.GameRecord
+		gm.inGame(a, func() { record = gameRecord(a.game) })
+		return record, nil
+	}
+
+	if gm.dbService != nil {
+		record, err := gm.dbService.GetGame(gameID)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			return record, nil
+		}
+	}
+	return nil, ErrGameNotFound
+}
+
+func gameRecord(game *models.Game) *services.GameRecord {
+	record := &services.GameRecord{
+		ID:            game.ID,
+		Player1:       game.Player1.Username,
+		Winner:        game.Winner,
+		Duration:      game.GetDuration(),
+		Moves:         len(game.Moves),
+		IsBot:         game.IsBot,
+		BotDifficulty: game.Difficulty,
+		BotSeat:       botSeat(game),
+		CreatedAt:     game.CreatedAt,
+		MoveHistory:   append([]models.Move(nil), game.Moves...),
+	}
+	if game.Player2 != nil {
+		record.Player2 = game.Player2.Username
+	}
+	if game.Status == "finished" {
+		finishedAt := game.LastMoveAt
+		record.FinishedAt = &finishedAt
+	}
+	return record
+}
+
+// StreamReplay re-emits the moves of record as move_made events. Gaps follow
+// the original timing divided by speed, or a fixed interval when given.
+func (gm *GameManager) StreamReplay(conn *websocket.Conn, record *services.GameRecord, speed float64, interval time.Duration) {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	gm.Connect(conn)
+	defer gm.clients.finish(conn)
+
+	// Stop as soon as the viewer goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("replay_started", map[string]interface{}{
+			"gameId": record.ID,
+			"speed":  speed,
+			"moves":  len(record.MoveHistory),
+		})
+	}
+
+	game := models.NewGame(
+		&models.Player{ID: newPlayerID(), Username: record.Player1, IsBot: record.BotSeat == 1},
+		&models.Player{ID: newPlayerID(), Username: record.Player2, IsBot: record.BotSeat == 2},
+	)
+	game.ID = record.ID
+	game.Status = "playing"
+	game.IsBot = record.IsBot
+	game.Difficulty = record.BotDifficulty
+	game.CreatedAt = record.CreatedAt
+
+	gm.sendMessage(conn, &protocol.ReplayStarted{
+		GameID:     record.ID,
+		Speed:      speed,
+		TotalMoves: len(record.MoveHistory),
+		GameState:  game,
+	})
+
+	for i, move := range record.MoveHistory {
+		gap := interval
+		if gap <= 0 {
+			gap = defaultReplayGap
+			if i > 0 {
+				gap = move.Timestamp.Sub(record.MoveHistory[i-1].Timestamp)
+				if gap > maxReplayGap {
+					gap = maxReplayGap
+				}
+			}
+			gap = time.Duration(float64(gap) / speed)
+		}
+		if gap < minReplayGap {
+			gap = minReplayGap
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-time.After(gap):
+		}
+
+		row, _, _, err := game.MakeMove(move.Column, move.Player)
+		if err != nil {
+			log.Printf("Replay of game %s stopped at move %d: %v", record.ID, i, err)
+			gm.sendError(conn, "", protocol.ErrInternal, "Replay data is corrupt")
+			return
+		}
+		game.LastMoveAt = move.Timestamp
+
+		moveIndex := i
+		gm.sendMessage(conn, &protocol.MoveMade{
+			Seq:       game.Seq,
+			Column:    move.Column,
+			Row:       row,
+			Player:    move.Player,
+			GameState: game,
+			MoveIndex: &moveIndex,
+		})
+	}
+
+	game.Status = "finished"
+	game.Winner = record.Winner
+	gm.sendMessage(conn, &protocol.ReplayEnded{Winner: record.Winner, GameState: game})
+}
@@ -0,0 +1,264 @@
+package game
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/services"
+)
+
+const (
+	reviewWorkers       = 2
+	reviewQueueSize     = 100
+	reviewMoveTimeLimit = 300 * time.Millisecond
+	reviewCacheTime     = 10 * time.Minute
+)
+
+// Move classifications, from best to worst
+const (
+	MoveBest       = "best"
+	MoveGood       = "good"
+	MoveInaccuracy = "inaccuracy"
+	MoveMistake    = "mistake"
+	MoveBlunder    = "blunder"
+)
+
+var classificationAccuracy = map[string]float64{
+	MoveBest:       100,
+	MoveGood:       90,
+	MoveInaccuracy: 60,
+	MoveMistake:    30,
+	MoveBlunder:    0,
+}
+
+type MoveReview struct {
+	MoveIndex      int     `json:"moveIndex"`
+	Player         int     `json:"player"`
+	Column         int     `json:"column"`
+	BestMove       int     `json:"bestMove"`
+	Score          float64 `json:"score"`
+	BestScore      float64 `json:"bestScore"`
+	Exact          bool    `json:"exact"`
+	Classification string  `json:"classification"`
+}
+
+type PlayerReview struct {
+	Username        string         `json:"username"`
+	Accuracy        float64        `json:"accuracy"`
+	Classifications map[string]int `json:"classifications"`
+}
+
+type GameReview struct {
+	GameID     string          `json:"gameId"`
+	Status     string          `json:"status"` // pending, complete
+	Players    []*PlayerReview `json:"players"`
+	Moves      []MoveReview    `json:"moves"`
+	ReviewedAt *time.Time      `json:"reviewedAt,omitempty"`
+}
+
+type reviewJob struct {
+	gameID  string
+	players [2]*models.Player
+	moves   []models.Move
+}
+
+func (gm *GameManager) startReviewWorkers() {
+	for i := 0; i < reviewWorkers; i++ {
+		go func() {
+			bot := NewBotWithDifficulty(DifficultyPerfect, gm.cfg.BotTableSizeMB)
+			if gm.book != nil {
+				bot.UseOpeningBook(gm.book)
+			}
+			for job := range gm.reviewQueue {
+				gm.runReview(bot, job)
+			}
+		}()
+	}
+}
+
+// queueReview schedules a finished game for background analysis. Callers hold gm.mu.
+func (gm *GameManager) queueReview(game *models.Game) {
+	if len(game.Moves) == 0 {
+		return
+	}
+
+	job := reviewJob{
+		gameID:  game.ID,
+		players: [2]*models.Player{game.Player1, game.Player2},
+		moves:   append([]models.Move(nil), game.Moves...),
+	}
+
+	select {
+	case gm.reviewQueue <- job:
+		gm.reviews[game.ID] = &GameReview{GameID: game.ID, Status: "pending"}
+	default:
+		log.Printf("Review queue full, skipping review for game %s", game.ID)
+	}
+}
+
+func (gm *GameManager) runReview(bot *Bot, job reviewJob) {
+	review := ReviewMoves(bot, job.moves, reviewMoveTimeLimit)
+	review.GameID = job.gameID
+	for i, player := range job.players {
+		review.Players[i].Username = player.Username
+	}
+
+	gm.mu.Lock()
+	gm.reviews[job.gameID] = review
+	gm.mu.Unlock()
+
+	time.AfterFunc(reviewCacheTime, func() {
+		gm.mu.Lock()
+		delete(gm.reviews, job.gameID)
+		gm.mu.Unlock()
+	})
+
+	if gm.dbService != nil {
+		data := services.GameReviewData{
+			GameID:  job.gameID,
+			Player1: job.players[0].Username,
+			Player2: job.players[1].Username,
+			Review:  review,
+		}
+		// Bots have no accuracy worth tracking
+		if !job.players[0].IsBot {
+			data.Player1Accuracy = &review.Players[0].Accuracy
+		}
+		if !job.players[1].IsBot {
+			data.Player2Accuracy = &review.Players[1].Accuracy
+		}
+		if err := gm.dbService.SaveGameReview(data); err != nil {
+			log.Printf("Failed to save review for game %s: %v", job.gameID, err)
+		}
+	}
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("game_reviewed", map[string]interface{}{
+			"gameId":          job.gameID,
+			"player1Accuracy": review.Players[0].Accuracy,
+			"player2Accuracy": review.Players[1].Accuracy,
+			"player1Blunders": review.Players[0].Classifications[MoveBlunder],
+			"player2Blunders": review.Players[1].Classifications[MoveBlunder],
+		})
+	}
+}
+
+// GetReview returns the review of a recently finished game, falling back to
+// the stored copy once it has left memory.
+func (gm *GameManager) GetReview(gameID string) (interface{}, error) {
+	gm.mu.RLock()
+	review, exists := gm.reviews[gameID]
+	gm.mu.RUnlock()
+	if exists {
+		return review, nil
+	}
+
+	if gm.dbService != nil {
+		stored, err := gm.dbService.GetGameReview(gameID)
+		if err == nil && stored != nil {
+			return stored, nil
+		}
+	}
+	return nil, ErrGameNotFound
+}
+
+// ReviewMoves replays moves from the empty board and classifies each one by
+// how much worse it scored than the engine's best move.
+func ReviewMoves(bot *Bot, moves []models.Move, moveTimeLimit time.Duration) *GameReview {
+	review := &GameReview{
+		Status:  "complete",
+		Players: []*PlayerReview{newPlayerReview(), newPlayerReview()},
+		Moves:   make([]MoveReview, 0, len(moves)),
+	}
+
+	pos := NewPosition()
+	for i, move := range moves {
+		if !pos.CanPlay(move.Column) {
+			break
+		}
+
+		analysis := bot.Analyze(context.Background(), pos, moveTimeLimit)
+		moveReview := MoveReview{
+			MoveIndex: i,
+			Player:    move.Player,
+			Column:    move.Column,
+			BestMove:  analysis.BestMove,
+			Exact:     analysis.Exact,
+		}
+		if analysis.BestMove >= 0 && analysis.ColumnScores[move.Column] != nil {
+			moveReview.BestScore = *analysis.ColumnScores[analysis.BestMove]
+			moveReview.Score = *analysis.ColumnScores[move.Column]
+		}
+		moveReview.Classification = classifyMove(moveReview)
+		review.Moves = append(review.Moves, moveReview)
+
+		player := review.Players[move.Player-1]
+		player.Classifications[moveReview.Classification]++
+
+		if pos.IsWinningMove(move.Column) {
+			break
+		}
+		pos.Play(move.Column)
+	}
+
+	for i, player := range review.Players {
+		total, count := 0.0, 0
+		for _, moveReview := range review.Moves {
+			if moveReview.Player == i+1 {
+				total += classificationAccuracy[moveReview.Classification]
+				count++
+			}
+		}
+		if count > 0 {
+			player.Accuracy = float64(int(total/float64(count)*10)) / 10
+		}
+	}
+
+	now := time.Now()
+	review.ReviewedAt = &now
+	return review
+}
+
+func newPlayerReview() *PlayerReview {
+	return &PlayerReview{Classifications: make(map[string]int)}
+}
+
+func classifyMove(move MoveReview) string {
+	if move.Column == move.BestMove || move.Score >= move.BestScore {
+		return MoveBest
+	}
+
+	if move.Exact {
+		// Solver scores: the sign is the outcome, the size how fast it comes
+		switch {
+		case move.BestScore >= 0 && move.Score < 0:
+			return MoveBlunder // turns a won or drawn game into a loss
+		case move.BestScore > 0 && move.Score == 0:
+			return MoveMistake // lets a won game slip to a draw
+		case move.BestScore-move.Score > 4:
+			return MoveInaccuracy // same result, but a much slower win or faster loss
+		}
+		return MoveGood
+	}
+
+	// Heuristic scores: forced wins and losses dominate, then the eval swing
+	switch {
+	case move.BestScore > -winThreshold && move.Score <= -winThreshold:
+		return MoveBlunder
+	case move.BestScore >= winThreshold && move.Score < winThreshold:
+		return MoveMistake
+	}
+
+	swing := move.BestScore - move.Score
+	switch {
+	case swing < 100:
+		return MoveGood
+	case swing < 250:
+		return MoveInaccuracy
+	case swing < 600:
+		return MoveMistake
+	}
+	return MoveBlunder
+}
@@ -0,0 +1,52 @@
+package game
+
+import (
+	"testing"
+
+	"emitrr-4-in-a-row/internal/models"
+)
+
+func movesOf(columns ...int) []models.Move {
+	moves := make([]models.Move, len(columns))
+	for i, col := range columns {
+		moves[i] = models.Move{Player: i%2 + 1, Column: col}
+	}
+	return moves
+}
+
+func TestReviewMovesClassifications(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves []int
+		want  map[int]string // by move index
+	}{
+		{
+			name:  "unblocked three then the win",
+			moves: []int{0, 6, 0, 6, 0, 5, 0},
+			want:  map[int]string{5: MoveBlunder, 6: MoveBest},
+		},
+		{
+			name:  "missed win",
+			moves: []int{0, 6, 0, 6, 0, 5, 3},
+			want:  map[int]string{5: MoveBlunder, 6: MoveMistake},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := NewBotWithDifficulty(DifficultyPerfect, 4)
+			review := ReviewMoves(bot, movesOf(tt.moves...), reviewMoveTimeLimit)
+
+			if len(review.Moves) != len(tt.moves) {
+				t.Fatalf("reviewed %d moves, want %d", len(review.Moves), len(tt.moves))
+			}
+			for index, want := range tt.want {
+				move := review.Moves[index]
+				if move.Classification != want {
+					t.Errorf("move %d (column %d): %s, want %s (best %d, score %.0f vs %.0f)",
+						index, move.Column, move.Classification, want, move.BestMove, move.Score, move.BestScore)
+				}
+			}
+		})
+	}
+}
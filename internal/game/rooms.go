@@ -0,0 +1,233 @@
+package game
+
+import (
+	"crypto/rand"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	roomCodeLength = 6
+	// No 0/O or 1/I/L so codes survive being read out loud
+	roomCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// Who moves first in a private room, from the creator's point of view
+const (
+	FirstMoveCreator = "creator"
+	FirstMoveInvitee = "invitee"
+	FirstMoveRandom  = "random"
+)
+
+// Room is a private game waiting for the invitee to arrive.
+type Room struct {
+	Code        string              `json:"code"`
+	Creator     string              `json:"creator"`
+	FirstMove   string              `json:"firstMove"`
+	Rated       bool                `json:"rated"`
+	TimeControl *models.TimeControl `json:"timeControl,omitempty"`
+	ExpiresAt   time.Time           `json:"expiresAt"`
+
+	creator   *Player
+	createdAt time.Time
+	timer     *time.Timer
+}
+
+func (gm *GameManager) HandleCreateRoom(conn *websocket.Conn, msg protocol.CreateRoom) *protocol.Error {
+	username, ok := parseUsername(msg.Username)
+	if !ok {
+		return protocol.NewError(protocol.ErrInvalidUsername, "Invalid username")
+	}
+
+	firstMove := FirstMoveCreator
+	switch msg.FirstMove {
+	case "":
+	case FirstMoveCreator, FirstMoveInvitee, FirstMoveRandom:
+		firstMove = msg.FirstMove
+	default:
+		return protocol.NewError(protocol.ErrBadRequest, "Invalid first move option")
+	}
+	rated := msg.Rated
+
+	timeControl := gm.timeControl
+	if msg.TimeControl != "" {
+		tc, err := models.ParseTimeControl(msg.TimeControl)
+		if err != nil {
+			return protocol.NewError(protocol.ErrBadRequest, "Invalid time control")
+		}
+		timeControl = tc
+	}
+
+	pvpRating, botRating := gm.loadRatings(username)
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if _, busy := gm.connections[conn]; busy || gm.spectators.isSpectating(conn) {
+		return protocol.NewError(protocol.ErrConflict, "Already in a game or queue")
+	}
+
+	code, err := gm.newRoomCode()
+	if err != nil {
+		log.Printf("Failed to generate room code: %v", err)
+		return protocol.NewError(protocol.ErrInternal, "Could not create room")
+	}
+
+	player := &Player{
+		ID:        newPlayerID(),
+		Username:  username,
+		Conn:      conn,
+		Rating:    pvpRating,
+		BotRating: botRating,
+		RoomCode:  code,
+	}
+	gm.connections[conn] = player
+
+	now := time.Now()
+	expiry := time.Duration(gm.cfg.RoomExpiryMinutes) * time.Minute
+	room := &Room{
+		Code:        code,
+		Creator:     username,
+		FirstMove:   firstMove,
+		Rated:       rated,
+		TimeControl: timeControl,
+		ExpiresAt:   now.Add(expiry),
+		creator:     player,
+		createdAt:   now,
+	}
+	room.timer = time.AfterFunc(expiry, func() { gm.expireRoom(room) })
+	gm.rooms[code] = room
+
+	gm.sendMessage(conn, &protocol.RoomCreated{
+		Code:        room.Code,
+		Creator:     room.Creator,
+		FirstMove:   room.FirstMove,
+		Rated:       room.Rated,
+		TimeControl: room.TimeControl,
+		ExpiresAt:   room.ExpiresAt,
+	})
+	log.Printf("Room %s created by %s", code, username)
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("room_created", map[string]interface{}{
+			"code":      code,
+			"player":    username,
+			"firstMove": firstMove,
+			"rated":     rated,
+		})
+	}
+	return nil
+}
+
+func (gm *GameManager) HandleJoinRoom(conn *websocket.Conn, msg protocol.JoinRoom) *protocol.Error {
+	username, ok := parseUsername(msg.Username)
+	if !ok {
+		return protocol.NewError(protocol.ErrInvalidUsername, "Invalid username")
+	}
+	code := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(msg.Code), "-", ""))
+
+	pvpRating, botRating := gm.loadRatings(username)
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	room, exists := gm.rooms[code]
+	if !exists {
+		return protocol.NewError(protocol.ErrNotFound, "Room not found or expired")
+	}
+	if room.creator.Conn == conn {
+		return protocol.NewError(protocol.ErrForbidden, "You can't join your own room")
+	}
+	if _, busy := gm.connections[conn]; busy || gm.spectators.isSpectating(conn) {
+		return protocol.NewError(protocol.ErrConflict, "Already in a game or queue")
+	}
+
+	invitee := &Player{
+		ID:        newPlayerID(),
+		Username:  username,
+		Conn:      conn,
+		Rating:    pvpRating,
+		BotRating: botRating,
+	}
+	gm.connections[conn] = invitee
+
+	creator := room.creator
+	gm.closeRoom(room)
+
+	creatorFirst := room.FirstMove == FirstMoveCreator
+	if room.FirstMove == FirstMoveRandom {
+		n, err := rand.Int(rand.Reader, big.NewInt(2))
+		creatorFirst = err != nil || n.Int64() == 0
+	}
+
+	opts := gameOptions{private: true, rated: room.Rated, timeControl: room.TimeControl}
+	var game *models.Game
+	if creatorFirst {
+		game = gm.startPvPGame(creator, invitee, opts)
+	} else {
+		game = gm.startPvPGame(invitee, creator, opts)
+	}
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("room_joined", map[string]interface{}{
+			"code":    room.Code,
+			"gameId":  game.ID,
+			"creator": creator.Username,
+			"invitee": username,
+			"wait":    time.Since(room.createdAt).Seconds(),
+		})
+	}
+	return nil
+}
+
+func (gm *GameManager) expireRoom(room *Room) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if gm.rooms[room.Code] != room {
+		return
+	}
+	gm.closeRoom(room)
+	delete(gm.connections, room.creator.Conn)
+	gm.sendMessage(room.creator.Conn, &protocol.RoomExpired{Code: room.Code})
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("room_expired", map[string]interface{}{
+			"code":   room.Code,
+			"player": room.Creator,
+		})
+	}
+}
+
+// closeRoom forgets a room without touching its creator's connection.
+// Callers hold gm.mu.
+func (gm *GameManager) closeRoom(room *Room) {
+	room.timer.Stop()
+	delete(gm.rooms, room.Code)
+	room.creator.RoomCode = ""
+}
+
+// newRoomCode picks an unused code. Callers hold gm.mu.
+func (gm *GameManager) newRoomCode() (string, error) {
+	max := big.NewInt(int64(len(roomCodeAlphabet)))
+	for {
+		var code strings.Builder
+		for i := 0; i < roomCodeLength; i++ {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			code.WriteByte(roomCodeAlphabet[n.Int64()])
+		}
+		if _, taken := gm.rooms[code.String()]; !taken {
+			return code.String(), nil
+		}
+	}
+}
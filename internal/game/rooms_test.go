@@ -0,0 +1,39 @@
+package game
+
+import (
+	"testing"
+
+	"emitrr-4-in-a-row/internal/protocol"
+)
+
+func TestJoinOwnRoom(t *testing.T) {
+	gm := newTestManager(t)
+
+	creator, _ := connect(t, gm)
+	if perr := gm.HandleCreateRoom(creator, protocol.CreateRoom{Username: "alice"}); perr != nil {
+		t.Fatalf("create room: %v", perr)
+	}
+	gm.mu.RLock()
+	var code string
+	for c := range gm.rooms {
+		code = c
+	}
+	gm.mu.RUnlock()
+
+	if perr := gm.HandleJoinRoom(creator, protocol.JoinRoom{Username: "alice", Code: code}); perr == nil || perr.Code != protocol.ErrForbidden {
+		t.Errorf("creator joining their own room: %v, want %s", perr, protocol.ErrForbidden)
+	}
+
+	// Another player who happens to share the name is a different player
+	invitee, _ := connect(t, gm)
+	if perr := gm.HandleJoinRoom(invitee, protocol.JoinRoom{Username: "alice", Code: code}); perr != nil {
+		t.Fatalf("namesake joining the room: %v", perr)
+	}
+
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	first, second := gm.connections[creator], gm.connections[invitee]
+	if first == nil || second == nil || first.GameID == "" || first.GameID != second.GameID || first.ID == second.ID {
+		t.Errorf("creator %+v and invitee %+v should share a game as different players", first, second)
+	}
+}
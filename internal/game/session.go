@@ -0,0 +1,76 @@
+package game
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// A session token lets a player take their seat back after a dropped
+// connection. It names the player, the game and the seat, signed with the
+// server's key, and is only honoured while that seat is waiting for them.
+type sessionClaims struct {
+	PlayerID string `json:"p"`
+	GameID   string `json:"g"`
+	Seat     int    `json:"s"`
+}
+
+// newSessionKey uses SESSION_SECRET if it is set. Otherwise tokens are
+// signed with a key made up at startup, which is enough as long as games
+// don't outlive the process either.
+func newSessionKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate session key: %v", err)
+	}
+	log.Printf("SESSION_SECRET not set, signing reconnect tokens with a random key")
+	return key
+}
+
+// newPlayerID identifies one player's session, from joining until they leave
+// for good. It is what models.Player.ID carries in their games.
+func newPlayerID() string {
+	return uuid.New().String()
+}
+
+func (gm *GameManager) sessionToken(playerID, gameID string, seat int) string {
+	payload, _ := json.Marshal(sessionClaims{PlayerID: playerID, GameID: gameID, Seat: seat})
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(gm.signSession(payload))
+}
+
+// parseSessionToken checks a token's signature and returns what it claims.
+func (gm *GameManager) parseSessionToken(token string) (sessionClaims, bool) {
+	var claims sessionClaims
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, gm.signSession(payload)) {
+		return claims, false
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, false
+	}
+	return claims, true
+}
+
+func (gm *GameManager) signSession(payload []byte) []byte {
+	mac := hmac.New(sha256.New, gm.sessionKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
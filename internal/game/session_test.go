@@ -0,0 +1,44 @@
+package game
+
+import (
+	"testing"
+
+	"emitrr-4-in-a-row/internal/protocol"
+	"emitrr-4-in-a-row/internal/rating"
+)
+
+func TestRejoinKeepsRatings(t *testing.T) {
+	gm := newTestManager(t)
+	aliceConn, _ := connect(t, gm)
+	bobConn, _ := connect(t, gm)
+
+	alice := &Player{
+		ID:         newPlayerID(),
+		Username:   "alice",
+		Conn:       aliceConn,
+		Difficulty: DifficultyCasual,
+		Rating:     rating.Rating{Rating: 1720, Deviation: 80, Volatility: 0.06},
+		BotRating:  rating.Rating{Rating: 1410, Deviation: 120, Volatility: 0.06},
+	}
+	bob := &Player{ID: newPlayerID(), Username: "bob", Conn: bobConn, Rating: rating.Default()}
+
+	gm.mu.Lock()
+	gm.connections[aliceConn], gm.connections[bobConn] = alice, bob
+	game := gm.startPvPGame(alice, bob, gameOptions{})
+	gm.mu.Unlock()
+
+	gm.HandlePlayerDisconnect(aliceConn)
+
+	rejoined, _ := connect(t, gm)
+	token := gm.sessionToken(alice.ID, game.ID, 1)
+	if perr := gm.HandleRejoin(rejoined, protocol.RejoinGame{SessionToken: token}); perr != nil {
+		t.Fatalf("rejoin: %v", perr)
+	}
+
+	gm.mu.RLock()
+	player := gm.connections[rejoined]
+	gm.mu.RUnlock()
+	if player.ID != alice.ID || player.Rating != alice.Rating || player.BotRating != alice.BotRating || player.Difficulty != alice.Difficulty {
+		t.Errorf("rejoined as %+v, want the ratings and difficulty of %+v", player, alice)
+	}
+}
@@ -0,0 +1,240 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Solver computes exact game-theoretic scores using the convention of a
+// strong Connect Four solver: a positive score means the side to move wins,
+// and the score is 22 minus the number of stones the winner has played when
+// the game ends (so faster wins score higher). Zero is a draw.
+type Solver struct {
+	table *TransTable
+	book  *OpeningBook
+
+	ctx      context.Context
+	nodes    uint64
+	deadline time.Time
+	aborted  bool
+}
+
+type PositionValue struct {
+	Outcome  string `json:"outcome"` // win, loss or draw for the side to move
+	Score    int    `json:"score"`
+	Plies    int    `json:"plies"` // half-moves until the game ends with best play
+	BestMove int    `json:"bestMove"`
+}
+
+func NewSolver(tableSizeMB int, book *OpeningBook) *Solver {
+	return &Solver{
+		table: NewTransTable(tableSizeMB),
+		book:  book,
+	}
+}
+
+func (s *Solver) TableStats() TransTableStats {
+	return s.table.Stats()
+}
+
+// Solve returns the exact score of pos. A zero timeLimit means no limit;
+// ok is false when the limit was reached or ctx was cancelled first.
+func (s *Solver) Solve(ctx context.Context, pos *Position, timeLimit time.Duration) (score int, ok bool) {
+	s.start(ctx, timeLimit)
+	score = s.solve(pos)
+	return score, !s.aborted
+}
+
+// Analyze scores every column of pos from the side to move's point of view.
+// Full columns are left out of the returned map.
+func (s *Solver) Analyze(ctx context.Context, pos *Position, timeLimit time.Duration) (map[int]int, bool) {
+	s.start(ctx, timeLimit)
+	scores := make(map[int]int, BoardWidth)
+	for _, col := range columnOrder {
+		if !pos.CanPlay(col) {
+			continue
+		}
+		if pos.IsWinningMove(col) {
+			scores[col] = (boardCells + 1 - pos.Moves()) / 2
+			continue
+		}
+		pos.Play(col)
+		scores[col] = -s.solve(pos)
+		pos.Undo()
+
+		if s.aborted {
+			return nil, false
+		}
+	}
+	return scores, true
+}
+
+// BestMove returns the highest scoring column, preferring the center on ties.
+func (s *Solver) BestMove(ctx context.Context, pos *Position, timeLimit time.Duration) (int, int, bool) {
+	scores, ok := s.Analyze(ctx, pos, timeLimit)
+	if !ok || len(scores) == 0 {
+		return -1, 0, false
+	}
+
+	bestCol := -1
+	for _, col := range columnOrder {
+		score, playable := scores[col]
+		if playable && (bestCol == -1 || score > scores[bestCol]) {
+			bestCol = col
+		}
+	}
+	return bestCol, scores[bestCol], true
+}
+
+// Value reports the outcome of pos with perfect play from both sides.
+func (s *Solver) Value(ctx context.Context, pos *Position, timeLimit time.Duration) (PositionValue, error) {
+	if pos.HasWon(1) || pos.HasWon(2) || pos.IsFull() {
+		return PositionValue{}, fmt.Errorf("game is already over")
+	}
+
+	col, score, ok := s.BestMove(ctx, pos, timeLimit)
+	if !ok {
+		return PositionValue{}, fmt.Errorf("position could not be solved in %s", timeLimit)
+	}
+	return NewPositionValue(pos, score, col), nil
+}
+
+// NewPositionValue converts a solver score for pos into a PositionValue.
+func NewPositionValue(pos *Position, score int, bestMove int) PositionValue {
+	value := PositionValue{Score: score, BestMove: bestMove}
+
+	switch {
+	case score > 0:
+		// side to move has played moves/2 stones and wins with its (22-score)th
+		value.Outcome = "win"
+		value.Plies = 2*(boardCells/2+1-score-pos.Moves()/2) - 1
+	case score < 0:
+		value.Outcome = "loss"
+		value.Plies = 2 * (boardCells/2 + 1 + score - (pos.Moves()+1)/2)
+	default:
+		value.Outcome = "draw"
+		value.Plies = boardCells - pos.Moves()
+	}
+	return value
+}
+
+func (s *Solver) start(ctx context.Context, timeLimit time.Duration) {
+	s.ctx = ctx
+	s.nodes = 0
+	s.aborted = false
+	s.deadline = time.Time{}
+	if timeLimit > 0 {
+		s.deadline = time.Now().Add(timeLimit)
+	}
+	s.table.NewSearch()
+}
+
+func (s *Solver) solve(pos *Position) int {
+	if pos.threats(pos.current()) != 0 {
+		return (boardCells + 1 - pos.Moves()) / 2
+	}
+
+	// Null-window searches narrowing [min, max] down to the exact score
+	min := -(boardCells - pos.Moves()) / 2
+	max := (boardCells + 1 - pos.Moves()) / 2
+	for min < max {
+		med := min + (max-min)/2
+		if med <= 0 && min/2 < med {
+			med = min / 2
+		} else if med >= 0 && max/2 > med {
+			med = max / 2
+		}
+
+		r := s.negamax(pos, med, med+1)
+		if s.aborted {
+			return 0
+		}
+		if r <= med {
+			max = r
+		} else {
+			min = r
+		}
+	}
+	return min
+}
+
+func (s *Solver) expired() bool {
+	return (!s.deadline.IsZero() && time.Now().After(s.deadline)) || s.ctx.Err() != nil
+}
+
+// negamax assumes the side to move cannot win with its next move.
+func (s *Solver) negamax(pos *Position, alpha, beta int) int {
+	s.nodes++
+	if s.nodes&4095 == 0 && s.expired() {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
+
+	next := pos.nonLosingMoves()
+	if next == 0 {
+		return -(boardCells - pos.Moves()) / 2
+	}
+	if pos.Moves() >= boardCells-2 {
+		return 0
+	}
+
+	min := -(boardCells - 2 - pos.Moves()) / 2
+	if alpha < min {
+		alpha = min
+		if alpha >= beta {
+			return alpha
+		}
+	}
+
+	if score, ok := s.book.lookup(pos); ok {
+		return score
+	}
+
+	max := (boardCells - 1 - pos.Moves()) / 2
+	key := pos.canonicalKey()
+	if entry, exists := s.table.Get(key); exists {
+		bound := int(entry.Score)
+		if entry.Flag == 1 && bound > min { // Lower bound
+			min = bound
+			if alpha < min {
+				alpha = min
+				if alpha >= beta {
+					return alpha
+				}
+			}
+		} else if entry.Flag == 2 && bound < max { // Upper bound
+			max = bound
+		}
+	}
+
+	if beta > max {
+		beta = max
+		if alpha >= beta {
+			return beta
+		}
+	}
+
+	depth := boardCells - pos.Moves()
+	for _, col := range pos.orderByThreats(next) {
+		pos.Play(col)
+		score := -s.negamax(pos, -beta, -alpha)
+		pos.Undo()
+
+		if s.aborted {
+			return 0
+		}
+		if score >= beta {
+			s.table.Put(key, TransEntry{Score: float64(score), Depth: depth, Flag: 1})
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	s.table.Put(key, TransEntry{Score: float64(alpha), Depth: depth, Flag: 2})
+	return alpha
+}
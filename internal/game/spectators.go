@@ -0,0 +1,245 @@
+package game
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"emitrr-4-in-a-row/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+// spectator is a read-only connection following one game. Like every
+// connection it writes through its client queue, so a slow viewer is dropped
+// instead of holding up the players.
+type spectator struct {
+	conn   *websocket.Conn
+	gameID string
+}
+
+// spectatorSet has its own lock so it can be consulted whether or not gm.mu
+// is held.
+type spectatorSet struct {
+	mu     sync.Mutex
+	byConn map[*websocket.Conn]*spectator
+}
+
+type LiveGame struct {
+	ID         string    `json:"id"`
+	Player1    string    `json:"player1"`
+	Player2    string    `json:"player2"`
+	Rating1    int       `json:"rating1,omitempty"`
+	Rating2    int       `json:"rating2,omitempty"`
+	IsBot      bool      `json:"isBot"`
+	Difficulty string    `json:"difficulty,omitempty"`
+	Rated      bool      `json:"rated"`
+	Moves      int       `json:"moves"`
+	Spectators int       `json:"spectators"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (gm *GameManager) HandleSpectate(conn *websocket.Conn, msg protocol.SpectateGame) *protocol.Error {
+	gameID := msg.GameID
+	notFound := protocol.NewError(protocol.ErrNotFound, "Game not found or already finished")
+
+	gm.mu.RLock()
+	if _, playing := gm.connections[conn]; playing {
+		gm.mu.RUnlock()
+		return protocol.NewError(protocol.ErrForbidden, "Players can't spectate from the same connection")
+	}
+
+	a, exists := gm.games[gameID]
+	gm.mu.RUnlock()
+	if !exists {
+		return notFound
+	}
+
+	// Subscribe and send the snapshot on the actor, so that no event can
+	// slip in between the two
+	var perr *protocol.Error
+	previous := ""
+	gm.inGame(a, func() {
+		if a.game.Status != "playing" {
+			perr = notFound
+			return
+		}
+		previous = gm.spectators.watch(conn, gameID)
+
+		missed, latest, ok := a.missedEvents(0, msg.LastEventSeq)
+		gm.sendMessage(conn, &protocol.Spectating{
+			GameState:  a.game,
+			Spectators: gm.spectators.count(gameID),
+			EventSeq:   latest,
+			CatchUp:    catchUpMode(ok),
+		})
+		gm.sendMissed(conn, missed)
+	})
+	if perr != nil {
+		return perr
+	}
+
+	gm.mu.Lock()
+	if previous != "" && previous != gameID {
+		gm.broadcastSpectatorCount(previous)
+	}
+	gm.broadcastSpectatorCount(gameID)
+	gm.mu.Unlock()
+
+	if gm.analyticsService != nil {
+		gm.analyticsService.TrackEvent("spectator_joined", map[string]interface{}{
+			"gameId":     gameID,
+			"spectators": gm.spectators.count(gameID),
+		})
+	}
+	return nil
+}
+
+// LiveGames lists games in progress that anyone can watch. Private games are
+// left out; they can still be spectated by ID.
+func (gm *GameManager) LiveGames() []LiveGame {
+	gm.mu.RLock()
+	var live []*gameActor
+	for _, a := range gm.games {
+		if !a.ended && !a.game.Private {
+			live = append(live, a)
+		}
+	}
+	gm.mu.RUnlock()
+
+	games := []LiveGame{}
+	for _, a := range live {
+		game := a.game
+		moves, playing := 0, false
+		gm.inGame(a, func() { moves, playing = len(game.Moves), game.Status == "playing" })
+		if !playing {
+			continue
+		}
+		games = append(games, LiveGame{
+			ID:         game.ID,
+			Player1:    game.Player1.Username,
+			Player2:    game.Player2.Username,
+			Rating1:    game.Player1.Rating,
+			Rating2:    game.Player2.Rating,
+			IsBot:      game.IsBot,
+			Difficulty: game.Difficulty,
+			Rated:      game.Rated,
+			Moves:      moves,
+			Spectators: gm.spectators.count(game.ID),
+			CreatedAt:  game.CreatedAt,
+		})
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if games[i].Spectators != games[j].Spectators {
+			return games[i].Spectators > games[j].Spectators
+		}
+		return games[i].CreatedAt.After(games[j].CreatedAt)
+	})
+	return games
+}
+
+// broadcastSpectatorCount tells everyone in a game how many people are
+// watching. Callers hold gm.mu.
+func (gm *GameManager) broadcastSpectatorCount(gameID string) {
+	gm.broadcastToGame(gameID, &protocol.SpectatorsUpdated{
+		GameID:     gameID,
+		Spectators: gm.spectators.count(gameID),
+	})
+}
+
+// stopSpectating forgets a spectator after they disconnect. Callers hold gm.mu.
+func (gm *GameManager) stopSpectating(conn *websocket.Conn) bool {
+	gameID := gm.spectators.remove(conn)
+	if gameID == "" {
+		return false
+	}
+	if _, exists := gm.games[gameID]; exists {
+		gm.broadcastSpectatorCount(gameID)
+	}
+	return true
+}
+
+// watch subscribes conn to gameID, moving it over if it was already watching
+// another game, and returns the game it was watching before.
+func (s *spectatorSet) watch(conn *websocket.Conn, gameID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.byConn[conn]; ok {
+		previous := existing.gameID
+		existing.gameID = gameID
+		return previous
+	}
+
+	s.byConn[conn] = &spectator{conn: conn, gameID: gameID}
+	return ""
+}
+
+func (s *spectatorSet) remove(conn *websocket.Conn) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp, ok := s.byConn[conn]
+	if !ok {
+		return ""
+	}
+	delete(s.byConn, conn)
+	return sp.gameID
+}
+
+// removeGame unsubscribes everyone watching a game that has been cleaned up.
+// Their connections stay open so they can pick another game.
+func (s *spectatorSet) removeGame(gameID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sp := range s.byConn {
+		if sp.gameID == gameID {
+			sp.gameID = ""
+		}
+	}
+}
+
+func (s *spectatorSet) count(gameID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, sp := range s.byConn {
+		if sp.gameID == gameID {
+			n++
+		}
+	}
+	return n
+}
+
+// watching is the game conn is spectating, if any.
+func (s *spectatorSet) watching(conn *websocket.Conn) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sp, ok := s.byConn[conn]; ok {
+		return sp.gameID
+	}
+	return ""
+}
+
+func (s *spectatorSet) isSpectating(conn *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.byConn[conn]
+	return ok
+}
+
+// watchers lists the connections watching gameID.
+func (s *spectatorSet) watchers(gameID string) []*websocket.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var conns []*websocket.Conn
+	for conn, sp := range s.byConn {
+		if sp.gameID == gameID {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
@@ -0,0 +1,136 @@
+package game
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	DefaultTransTableSizeMB = 4
+	transTableLockStripes   = 64
+)
+
+type TransEntry struct {
+	Score float64
+	Depth int
+	Flag  int // 0=exact, 1=lower, 2=upper
+}
+
+type transSlot struct {
+	key        uint64
+	score      float64
+	depth      int16
+	flag       int8
+	used       bool
+	generation uint32
+}
+
+// TransTable is a fixed-size, lock-striped transposition table. Slots are
+// addressed by hash, so memory never grows past the size given at creation.
+// On collision an entry is replaced when it is from an older search or was
+// searched no deeper than the incoming one.
+type TransTable struct {
+	slots      []transSlot
+	locks      [transTableLockStripes]sync.Mutex
+	generation atomic.Uint32
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	stores    atomic.Uint64
+	evictions atomic.Uint64
+	filled    atomic.Int64
+}
+
+type TransTableStats struct {
+	Capacity  int    `json:"capacity"`
+	Entries   int64  `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Stores    uint64 `json:"stores"`
+	Evictions uint64 `json:"evictions"`
+}
+
+func NewTransTable(sizeMB int) *TransTable {
+	if sizeMB <= 0 {
+		sizeMB = 1
+	}
+	capacity := sizeMB * 1024 * 1024 / int(unsafe.Sizeof(transSlot{}))
+	return &TransTable{slots: make([]transSlot, capacity)}
+}
+
+// NewSearch ages existing entries so they are preferred for replacement.
+func (t *TransTable) NewSearch() {
+	t.generation.Add(1)
+}
+
+func (t *TransTable) Get(key uint64) (TransEntry, bool) {
+	idx := key % uint64(len(t.slots))
+	lock := &t.locks[idx%transTableLockStripes]
+
+	lock.Lock()
+	slot := t.slots[idx]
+	lock.Unlock()
+
+	if !slot.used || slot.key != key {
+		t.misses.Add(1)
+		return TransEntry{}, false
+	}
+	t.hits.Add(1)
+	return TransEntry{Score: slot.score, Depth: int(slot.depth), Flag: int(slot.flag)}, true
+}
+
+func (t *TransTable) Put(key uint64, entry TransEntry) {
+	idx := key % uint64(len(t.slots))
+	lock := &t.locks[idx%transTableLockStripes]
+	generation := t.generation.Load()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	slot := &t.slots[idx]
+	if slot.used && slot.key != key {
+		if slot.generation == generation && int(slot.depth) > entry.Depth {
+			return
+		}
+		t.evictions.Add(1)
+	}
+	if !slot.used {
+		t.filled.Add(1)
+	}
+
+	*slot = transSlot{
+		key:        key,
+		score:      entry.Score,
+		depth:      int16(entry.Depth),
+		flag:       int8(entry.Flag),
+		used:       true,
+		generation: generation,
+	}
+	t.stores.Add(1)
+}
+
+func (t *TransTable) Stats() TransTableStats {
+	return TransTableStats{
+		Capacity:  len(t.slots),
+		Entries:   t.filled.Load(),
+		Bytes:     int64(len(t.slots)) * int64(unsafe.Sizeof(transSlot{})),
+		Hits:      t.hits.Load(),
+		Misses:    t.misses.Load(),
+		Stores:    t.stores.Load(),
+		Evictions: t.evictions.Load(),
+	}
+}
+
+func (s TransTableStats) Add(other TransTableStats) TransTableStats {
+	return TransTableStats{
+		Capacity:  s.Capacity + other.Capacity,
+		Entries:   s.Entries + other.Entries,
+		Bytes:     s.Bytes + other.Bytes,
+		Hits:      s.Hits + other.Hits,
+		Misses:    s.Misses + other.Misses,
+		Stores:    s.Stores + other.Stores,
+		Evictions: s.Evictions + other.Evictions,
+	}
+}
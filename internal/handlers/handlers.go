@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"emitrr-4-in-a-row/internal/game"
+	"emitrr-4-in-a-row/internal/protocol"
 	"emitrr-4-in-a-row/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -14,11 +18,11 @@ import (
 
 type Handler struct {
 	gameManager *game.GameManager
-	dbService   *services.DatabaseService
+	dbService   services.Storage
 	upgrader    websocket.Upgrader
 }
 
-func NewHandler(gameManager *game.GameManager, dbService *services.DatabaseService) *Handler {
+func NewHandler(gameManager *game.GameManager, dbService services.Storage) *Handler {
 	return &Handler{
 		gameManager: gameManager,
 		dbService:   dbService,
@@ -36,6 +40,16 @@ func (h *Handler) SetupRoutes(router *gin.Engine) {
 	{
 		api.GET("/leaderboard", h.getLeaderboard)
 		api.GET("/analytics", h.getAnalytics)
+		api.GET("/bot/stats", h.getBotStats)
+		api.GET("/matchmaking/stats", h.getMatchmakingStats)
+		api.POST("/analyze", h.analyzePosition)
+		api.GET("/games/live", h.getLiveGames)
+		api.GET("/games/:id", h.getGame)
+		api.GET("/games/:id/replay", h.replayGame)
+		api.GET("/games/:id/review", h.getGameReview)
+		api.GET("/players/:username/stats", h.getPlayerStats)
+		api.GET("/players/:username/accuracy", h.getPlayerAccuracy)
+		api.GET("/protocol/schema", h.getProtocolSchema)
 	}
 
 	// WebSocket endpoint
@@ -70,7 +84,12 @@ func (h *Handler) getLeaderboard(c *gin.Context) {
 		limit = 10
 	}
 
-	leaderboard, err := h.dbService.GetLeaderboard(limit)
+	var leaderboard []services.PlayerStats
+	if difficulty := c.Query("difficulty"); difficulty != "" {
+		leaderboard, err = h.dbService.GetBotLeaderboard(string(game.ParseDifficulty(difficulty)), limit)
+	} else {
+		leaderboard, err = h.dbService.GetLeaderboard(c.DefaultQuery("sort", services.LeaderboardByRating), limit)
+	}
 	if err != nil {
 		// Return empty leaderboard if DB unavailable
 		c.JSON(http.StatusOK, []interface{}{})
@@ -90,6 +109,141 @@ func (h *Handler) getAnalytics(c *gin.Context) {
 	c.JSON(http.StatusOK, analytics)
 }
 
+func (h *Handler) getBotStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.gameManager.BotStats())
+}
+
+func (h *Handler) getMatchmakingStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.gameManager.MatchmakingStats())
+}
+
+type analyzeRequest struct {
+	Board     [][]int `json:"board"`
+	GameID    string  `json:"gameId"`
+	MoveIndex *int    `json:"moveIndex"`
+}
+
+func (h *Handler) analyzePosition(c *gin.Context) {
+	var req analyzeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var analysis *game.PositionAnalysis
+	var err error
+	switch {
+	case req.GameID != "" && req.MoveIndex != nil:
+		analysis, err = h.gameManager.AnalyzeGameMove(req.GameID, *req.MoveIndex)
+	case req.Board != nil:
+		analysis, err = h.gameManager.AnalyzeBoard(req.Board)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a board or a gameId with moveIndex"})
+		return
+	}
+
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, game.ErrGameNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, game.ErrHintsDisabled), errors.Is(err, game.ErrGameInProgress):
+			status = http.StatusForbidden
+		case errors.Is(err, game.ErrAnalysisBusy):
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, analysis)
+}
+
+func (h *Handler) getLiveGames(c *gin.Context) {
+	c.JSON(http.StatusOK, h.gameManager.LiveGames())
+}
+
+func (h *Handler) getGame(c *gin.Context) {
+	record, err := h.gameManager.GetGameRecord(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, game.ErrGameNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch game"})
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// replayGame upgrades to a WebSocket that replays a game's moves.
+// ?speed=2 plays twice as fast as the original, ?interval=500 uses a fixed gap in ms.
+func (h *Handler) replayGame(c *gin.Context) {
+	record, err := h.gameManager.GetGameRecord(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+		return
+	}
+
+	speed, err := strconv.ParseFloat(c.DefaultQuery("speed", "1"), 64)
+	if err != nil || speed <= 0 {
+		speed = 1
+	}
+	intervalMs, _ := strconv.Atoi(c.DefaultQuery("interval", "0"))
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Replay upgrade failed: %v", err)
+		return
+	}
+
+	h.gameManager.StreamReplay(conn, record, speed, time.Duration(intervalMs)*time.Millisecond)
+}
+
+func (h *Handler) getGameReview(c *gin.Context) {
+	review, err := h.gameManager.GetReview(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	if pending, ok := review.(*game.GameReview); ok && pending.Status == "pending" {
+		c.JSON(http.StatusAccepted, pending)
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+func (h *Handler) getPlayerStats(c *gin.Context) {
+	stats, err := h.dbService.GetPlayerStats(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch player stats"})
+		return
+	}
+	if stats == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Player not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *Handler) getPlayerAccuracy(c *gin.Context) {
+	accuracy, err := h.dbService.GetPlayerAccuracy(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accuracy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accuracy)
+}
+
+// getProtocolSchema serves the JSON Schema of every WebSocket message.
+func (h *Handler) getProtocolSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, protocol.Schema())
+}
+
 func (h *Handler) handleWebSocket(c *gin.Context) {
 	log.Printf("WebSocket upgrade attempt from: %s", c.Request.RemoteAddr)
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -98,53 +252,114 @@ func (h *Handler) handleWebSocket(c *gin.Context) {
 		return
 	}
 	defer conn.Close()
+	h.gameManager.Connect(conn)
 
 	log.Printf("Player connected successfully: %s", conn.RemoteAddr())
-	
 
+	// Clients that skip the hello handshake speak the oldest version
+	version := protocol.MinVersion
+	received := 0
 
 	// Handle messages
 	for {
-
-		
-		var message map[string]interface{}
-		err := conn.ReadJSON(&message)
+		_, raw, err := conn.ReadMessage()
 		if err != nil {
-			log.Printf("WebSocket read error: %v", err)
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("WebSocket %s missed its heartbeat, treating it as disconnected", conn.RemoteAddr())
+			} else {
+				log.Printf("WebSocket read error: %v", err)
+			}
 			break
 		}
+		received++
 
-		log.Printf("Received WebSocket message: %+v", message)
+		log.Printf("Received WebSocket message: %s", raw)
 
-		messageType, ok := message["type"].(string)
-		if !ok {
-			log.Printf("Invalid message type: %+v", message["type"])
+		env, msg, perr := protocol.Parse(raw)
+		if perr != nil {
+			log.Printf("Rejected WebSocket message: %v", perr)
+			h.gameManager.Send(conn, perr)
 			continue
 		}
 
-		data, ok := message["data"].(map[string]interface{})
-		if !ok {
-			log.Printf("Invalid data format: %+v", message["data"])
-			data = make(map[string]interface{})
-		}
-
-		switch messageType {
-		case "join_game":
-			log.Printf("Processing join_game with data: %+v", data)
-			h.gameManager.HandlePlayerJoin(conn, data)
-		case "make_move":
-			log.Printf("Processing make_move: %+v", data)
-			h.gameManager.HandlePlayerMove(conn, data)
-		case "rejoin_game":
-			log.Printf("Processing rejoin_game: %+v", data)
-			h.gameManager.HandlePlayerJoin(conn, data)
-
-		default:
-			log.Printf("Unknown message type: %s", messageType)
+		log.Printf("Processing %s: %+v", env.Type, msg)
+		var ack *protocol.Ack
+		switch msg := msg.(type) {
+		case *protocol.Hello:
+			if received > 1 {
+				h.gameManager.Send(conn, env.Error(protocol.ErrBadRequest, "hello must be the first message"))
+				continue
+			}
+			negotiated, perr := protocol.Negotiate(msg.Version)
+			if perr != nil {
+				perr.RequestID = env.RequestID
+				h.gameManager.Send(conn, perr)
+				continue
+			}
+			version = negotiated
+			log.Printf("Client %q at %s speaks protocol v%d", msg.Client, conn.RemoteAddr(), version)
+			h.gameManager.Send(conn, &protocol.Welcome{
+				Version:    version,
+				MinVersion: protocol.MinVersion,
+				MaxVersion: protocol.Version,
+			})
+		case *protocol.JoinGame:
+			perr = h.gameManager.HandlePlayerJoin(conn, *msg)
+		case *protocol.RejoinGame:
+			perr = h.gameManager.HandleRejoin(conn, *msg)
+		case *protocol.MakeMove:
+			ack, perr = h.gameManager.HandlePlayerMove(conn, env.RequestID, *msg)
+		case *protocol.CreateRoom:
+			perr = h.gameManager.HandleCreateRoom(conn, *msg)
+		case *protocol.JoinRoom:
+			perr = h.gameManager.HandleJoinRoom(conn, *msg)
+		case *protocol.SpectateGame:
+			perr = h.gameManager.HandleSpectate(conn, *msg)
+		case *protocol.Resign:
+			perr = h.gameManager.HandleResign(conn, *msg)
+		case *protocol.OfferDraw:
+			perr = h.gameManager.HandleOfferDraw(conn, *msg)
+		case *protocol.RespondDraw:
+			perr = h.gameManager.HandleRespondDraw(conn, *msg)
+		case *protocol.RequestTakeback:
+			perr = h.gameManager.HandleRequestTakeback(conn, *msg)
+		case *protocol.RespondTakeback:
+			perr = h.gameManager.HandleRespondTakeback(conn, *msg)
+		case *protocol.OfferRematch:
+			perr = h.gameManager.HandleOfferRematch(conn, *msg)
+		case *protocol.AcceptRematch:
+			perr = h.gameManager.HandleAcceptRematch(conn, *msg)
+		case *protocol.DeclineRematch:
+			perr = h.gameManager.HandleDeclineRematch(conn, *msg)
+		case *protocol.RequestHint:
+			perr = h.gameManager.HandleHintRequest(conn, env.RequestID, *msg)
+		case *protocol.Resync:
+			perr = h.gameManager.HandleResync(conn, *msg)
 		}
+		h.reply(conn, env, ack, perr)
 	}
 
 	// Handle disconnect
 	h.gameManager.HandlePlayerDisconnect(conn)
 	log.Printf("Player disconnected: %s", conn.RemoteAddr())
-}
\ No newline at end of file
+}
+
+// reply answers a request with its error, or with an ack if it carried a
+// requestId.
+func (h *Handler) reply(conn *websocket.Conn, env protocol.Envelope, ack *protocol.Ack, perr *protocol.Error) {
+	if perr != nil {
+		perr.RequestID = env.RequestID
+		h.gameManager.Send(conn, perr)
+		return
+	}
+	if env.RequestID == "" {
+		return
+	}
+	if ack == nil {
+		ack = &protocol.Ack{}
+	}
+	ack.RequestID = env.RequestID
+	ack.Type = env.Type
+	h.gameManager.Send(conn, ack)
+}
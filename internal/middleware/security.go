@@ -35,4 +35,4 @@ func ValidateMove(column int) ValidationResult {
 		return ValidationResult{Valid: false, Error: "Invalid column"}
 	}
 	return ValidationResult{Valid: true, Column: column}
-}
\ No newline at end of file
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeControl limits how long each player may think: either a total budget
+// per player topped up by an increment after every move, or a fixed limit
+// for each move.
+type TimeControl struct {
+	Initial   int `json:"initial,omitempty"`   // seconds per player
+	Increment int `json:"increment,omitempty"` // seconds added after each move
+	PerMove   int `json:"perMove,omitempty"`   // seconds for every move, instead of a total
+}
+
+// ParseTimeControl reads "3+2" (3 minutes plus 2 seconds a move) or
+// "30/move" (30 seconds for every move). An empty string or "none" means
+// the game is untimed and returns nil.
+func ParseTimeControl(s string) (*TimeControl, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+
+	if seconds, ok := strings.CutSuffix(s, "/move"); ok {
+		perMove, err := strconv.Atoi(seconds)
+		if err != nil || perMove <= 0 {
+			return nil, fmt.Errorf("invalid per-move time control %q", s)
+		}
+		return &TimeControl{PerMove: perMove}, nil
+	}
+
+	minutes, increment, _ := strings.Cut(s, "+")
+	initial, err := strconv.ParseFloat(minutes, 64)
+	if err != nil || initial <= 0 {
+		return nil, fmt.Errorf("invalid time control %q", s)
+	}
+	tc := &TimeControl{Initial: int(initial * 60)}
+	if increment != "" {
+		if tc.Increment, err = strconv.Atoi(increment); err != nil || tc.Increment < 0 {
+			return nil, fmt.Errorf("invalid increment in time control %q", s)
+		}
+	}
+	return tc, nil
+}
+
+func (tc *TimeControl) String() string {
+	if tc.PerMove > 0 {
+		return fmt.Sprintf("%d/move", tc.PerMove)
+	}
+	return fmt.Sprintf("%s+%d", strconv.FormatFloat(float64(tc.Initial)/60, 'f', -1, 64), tc.Increment)
+}
+
+// timeNow is when moves and takebacks happen, swapped out by tests.
+var timeNow = time.Now
+
+// Clock is the time each player has left, in milliseconds. Only the player to
+// move loses time, counted from TurnStarted, and nobody does while Paused.
+type Clock struct {
+	Player1     int64     `json:"player1"`
+	Player2     int64     `json:"player2"`
+	TurnStarted time.Time `json:"turnStarted"`
+	Paused      bool      `json:"paused"`
+}
+
+func (g *Game) StartClock(tc *TimeControl, now time.Time) {
+	g.TimeControl = tc
+	if tc == nil {
+		g.Clock = nil
+		return
+	}
+
+	budget := time.Duration(tc.Initial) * time.Second
+	if tc.PerMove > 0 {
+		budget = time.Duration(tc.PerMove) * time.Second
+	}
+	g.Clock = &Clock{
+		Player1:     budget.Milliseconds(),
+		Player2:     budget.Milliseconds(),
+		TurnStarted: now,
+	}
+}
+
+// RemainingTime is how long player has left at now.
+func (g *Game) RemainingTime(player int, now time.Time) time.Duration {
+	if g.Clock == nil {
+		return 0
+	}
+	remaining := time.Duration(g.Clock.Player1) * time.Millisecond
+	if player == 2 {
+		remaining = time.Duration(g.Clock.Player2) * time.Millisecond
+	}
+	if g.clockRunning() && player == g.CurrentPlayer {
+		remaining -= now.Sub(g.Clock.TurnStarted)
+	}
+	return remaining
+}
+
+// OutOfTime reports whether the player to move has run out of time.
+func (g *Game) OutOfTime(now time.Time) bool {
+	return g.clockRunning() && g.RemainingTime(g.CurrentPlayer, now) <= 0
+}
+
+// ClockState is the clock as of now, for sending to clients.
+func (g *Game) ClockState(now time.Time) *Clock {
+	if g.Clock == nil {
+		return nil
+	}
+	return &Clock{
+		Player1:     max(g.RemainingTime(1, now).Milliseconds(), 0),
+		Player2:     max(g.RemainingTime(2, now).Milliseconds(), 0),
+		TurnStarted: now,
+		Paused:      g.Clock.Paused,
+	}
+}
+
+// PauseClock stops the clock of the player to move, e.g. while someone is
+// reconnecting. ResumeClock picks up where it left off.
+func (g *Game) PauseClock(now time.Time) {
+	if !g.clockRunning() {
+		return
+	}
+	g.chargeClock(g.CurrentPlayer, now)
+	g.Clock.Paused = true
+}
+
+func (g *Game) ResumeClock(now time.Time) {
+	if g.Clock == nil || !g.Clock.Paused {
+		return
+	}
+	g.Clock.Paused = false
+	g.Clock.TurnStarted = now
+}
+
+func (g *Game) clockRunning() bool {
+	return g.Clock != nil && !g.Clock.Paused && g.Status == "playing"
+}
+
+// chargeClock takes the time since the turn started off player's clock.
+func (g *Game) chargeClock(player int, now time.Time) {
+	elapsed := now.Sub(g.Clock.TurnStarted).Milliseconds()
+	if player == 1 {
+		g.Clock.Player1 -= elapsed
+	} else {
+		g.Clock.Player2 -= elapsed
+	}
+	g.Clock.TurnStarted = now
+}
+
+// pressClock ends player's turn: their thinking time is charged, then the
+// increment added, or with a per-move limit their clock is reset for next time.
+func (g *Game) pressClock(player int, now time.Time) {
+	if g.Clock == nil {
+		return
+	}
+	if !g.Clock.Paused {
+		g.chargeClock(player, now)
+	}
+	g.Clock.TurnStarted = now
+
+	bonus := time.Duration(g.TimeControl.Increment) * time.Second
+	if g.TimeControl.PerMove > 0 {
+		bonus = 0
+		if player == 1 {
+			g.Clock.Player1 = int64(g.TimeControl.PerMove) * 1000
+		} else {
+			g.Clock.Player2 = int64(g.TimeControl.PerMove) * 1000
+		}
+	}
+	if player == 1 {
+		g.Clock.Player1 += bonus.Milliseconds()
+	} else {
+		g.Clock.Player2 += bonus.Milliseconds()
+	}
+}
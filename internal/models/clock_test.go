@@ -0,0 +1,109 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// newTimedGame starts a game between two players, the second a bot if
+// vsBot, with the clock set going at start. The returned function moves the
+// time moves are made at to start+offset.
+func newTimedGame(t *testing.T, tc string, vsBot bool, start time.Time) (*Game, func(offset time.Duration)) {
+	t.Helper()
+	timeControl, err := ParseTimeControl(tc)
+	if err != nil {
+		t.Fatalf("parse %q: %v", tc, err)
+	}
+
+	game := NewGame(&Player{ID: "p1", Username: "alice"}, &Player{ID: "p2", Username: "bob", IsBot: vsBot})
+	game.Status = "playing"
+	game.StartClock(timeControl, start)
+
+	now := start
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+	return game, func(offset time.Duration) { now = start.Add(offset) }
+}
+
+func TestClockFlagFalls(t *testing.T) {
+	start := time.Now()
+	game, _ := newTimedGame(t, "1+0", false, start)
+
+	if game.OutOfTime(start.Add(59 * time.Second)) {
+		t.Errorf("out of time with a second left")
+	}
+	if !game.OutOfTime(start.Add(time.Minute)) {
+		t.Errorf("still in time once the minute is up")
+	}
+	if got := game.RemainingTime(2, start.Add(time.Minute)); got != time.Minute {
+		t.Errorf("player 2 lost time while waiting: %v left", got)
+	}
+
+	// Nobody flags while the clock is paused
+	game.PauseClock(start.Add(30 * time.Second))
+	if game.OutOfTime(start.Add(time.Hour)) {
+		t.Errorf("out of time while paused")
+	}
+	game.ResumeClock(start.Add(time.Hour))
+	if got := game.RemainingTime(1, start.Add(time.Hour+10*time.Second)); got != 20*time.Second {
+		t.Errorf("%v left after resuming, want 20s", got)
+	}
+}
+
+func TestClockIncrement(t *testing.T) {
+	start := time.Now()
+	game, at := newTimedGame(t, "1+2", false, start)
+
+	at(5 * time.Second)
+	if _, _, _, err := game.MakeMove(3, 1); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if game.Clock.Player1 != 57000 || game.Clock.Player2 != 60000 {
+		t.Errorf("clocks %d/%d after a 5s move, want 57000/60000", game.Clock.Player1, game.Clock.Player2)
+	}
+	if !game.Clock.TurnStarted.Equal(start.Add(5 * time.Second)) {
+		t.Errorf("player 2's turn started at %v", game.Clock.TurnStarted)
+	}
+
+	at(15 * time.Second)
+	if _, _, _, err := game.MakeMove(3, 2); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if game.Clock.Player1 != 57000 || game.Clock.Player2 != 52000 {
+		t.Errorf("clocks %d/%d after a 10s reply, want 57000/52000", game.Clock.Player1, game.Clock.Player2)
+	}
+}
+
+func TestBotClock(t *testing.T) {
+	start := time.Now()
+	game, at := newTimedGame(t, "1+0", true, start)
+
+	at(2 * time.Second)
+	game.MakeMove(0, 1)
+
+	// The bot's thinking time comes off its own clock
+	at(9 * time.Second)
+	if _, _, _, err := game.MakeMove(1, 2); err != nil {
+		t.Fatalf("bot move: %v", err)
+	}
+	if game.Clock.Player1 != 58000 || game.Clock.Player2 != 53000 {
+		t.Errorf("clocks %d/%d, want 58000/53000", game.Clock.Player1, game.Clock.Player2)
+	}
+}
+
+func TestPerMoveClock(t *testing.T) {
+	start := time.Now()
+	game, at := newTimedGame(t, "30/move", true, start)
+
+	at(25 * time.Second)
+	game.MakeMove(0, 1)
+	if game.Clock.Player1 != 30000 {
+		t.Errorf("player 1 has %dms for their next move, want a fresh 30000", game.Clock.Player1)
+	}
+	if game.OutOfTime(start.Add(54 * time.Second)) {
+		t.Errorf("bot out of time with a second left")
+	}
+	if !game.OutOfTime(start.Add(55 * time.Second)) {
+		t.Errorf("bot still in time after 30s")
+	}
+}
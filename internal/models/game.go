@@ -7,9 +7,11 @@ import (
 )
 
 type Player struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	IsBot    bool   `json:"isBot"`
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	IsBot       bool   `json:"isBot"`
+	Rating      int    `json:"rating,omitempty"` // PvP or vs-bot rating, matching the game type
+	Provisional bool   `json:"provisional,omitempty"`
 }
 
 type Move struct {
@@ -20,17 +22,64 @@ type Move struct {
 }
 
 type Game struct {
-	ID            string    `json:"id"`
-	Player1       *Player   `json:"player1"`
-	Player2       *Player   `json:"player2"`
-	Board         [][]int   `json:"board"`
-	CurrentPlayer int       `json:"currentPlayer"`
-	Status        string    `json:"status"` // waiting, playing, finished
-	Winner        *int      `json:"winner"`
-	CreatedAt     time.Time `json:"createdAt"`
-	LastMoveAt    time.Time `json:"lastMoveAt"`
-	Moves         []Move    `json:"moves"`
-	IsBot         bool      `json:"isBot"`
+	ID            string       `json:"id"`
+	Player1       *Player      `json:"player1"`
+	Player2       *Player      `json:"player2"`
+	Board         [][]int      `json:"board"`
+	CurrentPlayer int          `json:"currentPlayer"`
+	Status        string       `json:"status"` // waiting, playing, finished
+	Winner        *int         `json:"winner"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	LastMoveAt    time.Time    `json:"lastMoveAt"`
+	Moves         []Move       `json:"moves"`
+	Seq           int          `json:"seq"` // bumped by every move and takeback
+	IsBot         bool         `json:"isBot"`
+	Difficulty    string       `json:"difficulty,omitempty"`
+	Private       bool         `json:"private,omitempty"` // started from a room code
+	Rated         bool         `json:"rated"`
+	HintsUsed     int          `json:"hintsUsed"`
+	AbandonedBy   int          `json:"abandonedBy,omitempty"` // player who left and never came back
+	TimeControl   *TimeControl `json:"timeControl,omitempty"`
+	Clock         *Clock       `json:"clock,omitempty"`
+	TimedOut      int          `json:"timedOut,omitempty"` // player who lost on time
+	EndReason     string       `json:"endReason,omitempty"`
+	Takebacks     int          `json:"takebacks"`
+	Series        *Series      `json:"series,omitempty"`
+}
+
+// Why a game finished
+const (
+	EndConnectFour = "connect-four"
+	EndResign      = "resign"
+	EndDrawAgreed  = "draw-agreed"
+	EndBoardFull   = "board-full"
+	EndTimeout     = "timeout"
+	EndAbandonment = "abandonment"
+)
+
+// Series is the running score between two players who keep rematching.
+// Colours swap every game, so wins are kept by username.
+type Series struct {
+	Wins  map[string]int `json:"wins"`
+	Draws int            `json:"draws"`
+	Games int            `json:"games"`
+}
+
+func NewSeries(player1, player2 string) *Series {
+	return &Series{Wins: map[string]int{player1: 0, player2: 0}}
+}
+
+// Record adds a finished game to the series score.
+func (s *Series) Record(game *Game) {
+	s.Games++
+	switch {
+	case game.Winner == nil:
+		s.Draws++
+	case *game.Winner == 1:
+		s.Wins[game.Player1.Username]++
+	default:
+		s.Wins[game.Player2.Username]++
+	}
 }
 
 func NewGame(player1 *Player, player2 *Player) *Game {
@@ -89,25 +138,30 @@ func (g *Game) MakeMove(column int, playerNumber int) (int, bool, *int, error) {
 	}
 
 	// Make the move
+	now := timeNow()
 	g.Board[row][column] = playerNumber
 	g.Moves = append(g.Moves, Move{
 		Player:    playerNumber,
 		Row:       row,
 		Column:    column,
-		Timestamp: time.Now(),
+		Timestamp: now,
 	})
-	g.LastMoveAt = time.Now()
+	g.LastMoveAt = now
+	g.Seq++
+	g.pressClock(playerNumber, now)
 
 	// Check for win
 	if g.CheckWin(row, column, playerNumber) {
 		g.Status = "finished"
 		g.Winner = &playerNumber
+		g.EndReason = EndConnectFour
 		return row, true, &playerNumber, nil
 	}
 
 	// Check for draw
 	if g.IsBoardFull() {
 		g.Status = "finished"
+		g.EndReason = EndBoardFull
 		return row, true, nil, nil
 	}
 
@@ -121,6 +175,33 @@ func (g *Game) MakeMove(column int, playerNumber int) (int, bool, *int, error) {
 	return row, false, nil, nil
 }
 
+// UndoMove takes back the last move and hands the turn back to whoever made
+// it. Time already spent stays spent; the clock just restarts for them.
+func (g *Game) UndoMove() (Move, error) {
+	if g.Status != "playing" {
+		return Move{}, &GameError{"Game not active"}
+	}
+	if len(g.Moves) == 0 {
+		return Move{}, &GameError{"No moves to take back"}
+	}
+
+	now := timeNow()
+	if g.clockRunning() {
+		g.chargeClock(g.CurrentPlayer, now)
+	}
+
+	last := g.Moves[len(g.Moves)-1]
+	g.Moves = g.Moves[:len(g.Moves)-1]
+	g.Board[last.Row][last.Column] = 0
+	g.CurrentPlayer = last.Player
+	g.LastMoveAt = now
+	g.Seq++
+	if g.Clock != nil {
+		g.Clock.TurnStarted = now
+	}
+	return last, nil
+}
+
 func (g *Game) CheckWin(row, col, player int) bool {
 	directions := [][]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
 
@@ -186,6 +267,27 @@ func (g *Game) GetPlayerNumberByUsername(username string) int {
 	return 0
 }
 
+// BoardAt rebuilds the board as it was after the first moveIndex moves.
+func (g *Game) BoardAt(moveIndex int) ([][]int, error) {
+	return BoardFromMoves(g.Moves, moveIndex)
+}
+
+// BoardFromMoves replays the first moveIndex moves onto an empty board.
+func BoardFromMoves(moves []Move, moveIndex int) ([][]int, error) {
+	if moveIndex < 0 || moveIndex > len(moves) {
+		return nil, &GameError{"Invalid move index"}
+	}
+
+	board := make([][]int, 6)
+	for i := range board {
+		board[i] = make([]int, 7)
+	}
+	for _, move := range moves[:moveIndex] {
+		board[move.Row][move.Column] = move.Player
+	}
+	return board, nil
+}
+
 func (g *Game) GetDuration() int {
 	endTime := g.LastMoveAt
 	if g.Status == "finished" {
@@ -202,4 +304,4 @@ type GameError struct {
 
 func (e *GameError) Error() string {
 	return e.Message
-}
\ No newline at end of file
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUndoMove(t *testing.T) {
+	start := time.Now()
+	game, at := newTimedGame(t, "1+0", false, start)
+
+	at(2 * time.Second)
+	game.MakeMove(3, 1)
+	at(5 * time.Second)
+	game.MakeMove(3, 2)
+	seq := game.Seq
+
+	// Player 1 thinks for 4s, then player 2 takes their move back
+	at(9 * time.Second)
+	last, err := game.UndoMove()
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if last.Player != 2 || last.Row != 4 || last.Column != 3 {
+		t.Errorf("undid %+v, want player 2's move at row 4 column 3", last)
+	}
+	if game.Board[4][3] != 0 || game.Board[5][3] != 1 || len(game.Moves) != 1 {
+		t.Errorf("board %v with %d moves after the takeback", game.Board, len(game.Moves))
+	}
+	if game.CurrentPlayer != 2 || game.Seq != seq+1 {
+		t.Errorf("player %d to move at seq %d, want player 2 at seq %d", game.CurrentPlayer, game.Seq, seq+1)
+	}
+
+	// The time player 1 spent waiting stays spent, and player 2's clock
+	// restarts from the takeback
+	if game.Clock.Player1 != 54000 || game.Clock.Player2 != 57000 {
+		t.Errorf("clocks %d/%d, want 54000/57000", game.Clock.Player1, game.Clock.Player2)
+	}
+	if !game.Clock.TurnStarted.Equal(start.Add(9 * time.Second)) {
+		t.Errorf("turn started at %v", game.Clock.TurnStarted)
+	}
+
+	game.UndoMove()
+	if _, err := game.UndoMove(); err == nil {
+		t.Errorf("undid a move on an empty board")
+	}
+	if game.CurrentPlayer != 1 || game.Board[5][3] != 0 {
+		t.Errorf("player %d to move on %v, want an empty board with player 1 to move", game.CurrentPlayer, game.Board)
+	}
+
+	game.Status = "finished"
+	game.Moves = []Move{{Player: 1, Row: 5, Column: 0}}
+	if _, err := game.UndoMove(); err == nil {
+		t.Errorf("undid a move in a finished game")
+	}
+}
@@ -0,0 +1,188 @@
+package protocol
+
+import "errors"
+
+// clientMessages lists everything a client may send; Parse and Schema both
+// work from it.
+var clientMessages = []Message{
+	&Hello{},
+	&JoinGame{},
+	&RejoinGame{},
+	&MakeMove{},
+	&CreateRoom{},
+	&JoinRoom{},
+	&SpectateGame{},
+	&Resign{},
+	&OfferDraw{},
+	&RespondDraw{},
+	&RequestTakeback{},
+	&RespondTakeback{},
+	&OfferRematch{},
+	&AcceptRematch{},
+	&DeclineRematch{},
+	&RequestHint{},
+	&Resync{},
+}
+
+// Hello announces the highest protocol version the client speaks. It must
+// be the first message on a connection; the server answers with welcome.
+type Hello struct {
+	Version int    `json:"version" jsonschema:"minimum=1"`
+	Client  string `json:"client,omitempty"` // free-form name and version, for logs
+}
+
+// JoinGame queues for a game. The bot takes over if nobody is matched in
+// time.
+type JoinGame struct {
+	Username   string `json:"username" jsonschema:"minLength=2"`
+	Difficulty string `json:"difficulty,omitempty"` // for the bot: beginner, casual, strong or perfect
+}
+
+// RejoinGame takes a seat back within the reconnect window, using the
+// sessionToken from game_started. LastEventSeq asks for the events missed
+// since then.
+type RejoinGame struct {
+	SessionToken string `json:"sessionToken" jsonschema:"minLength=1"`
+	LastEventSeq *int   `json:"lastEventSeq,omitempty" jsonschema:"minimum=0"`
+}
+
+// MakeMove drops a disc. Seq, if given, is the game's seq the client last
+// saw; the move is rejected with stale_seq if the game has moved on since.
+type MakeMove struct {
+	GameID string `json:"gameId"`
+	Column *int   `json:"column" jsonschema:"minimum=0,maximum=6,notnull"`
+	Seq    *int   `json:"seq,omitempty" jsonschema:"minimum=0"`
+}
+
+type CreateRoom struct {
+	Username    string `json:"username" jsonschema:"minLength=2"`
+	FirstMove   string `json:"firstMove,omitempty" jsonschema:"enum=creator|invitee|random"`
+	Rated       bool   `json:"rated,omitempty"`
+	TimeControl string `json:"timeControl,omitempty"` // e.g. 3+2, 30/move or none
+}
+
+type JoinRoom struct {
+	Username string `json:"username" jsonschema:"minLength=2"`
+	Code     string `json:"code"`
+}
+
+// SpectateGame starts watching a game. Spectators coming back after a
+// dropped connection send the last eventSeq they saw to catch up.
+type SpectateGame struct {
+	GameID       string `json:"gameId"`
+	LastEventSeq *int   `json:"lastEventSeq,omitempty" jsonschema:"minimum=0"`
+}
+
+type Resign struct {
+	GameID string `json:"gameId"`
+}
+
+type OfferDraw struct {
+	GameID string `json:"gameId"`
+}
+
+type RespondDraw struct {
+	GameID string `json:"gameId"`
+	Accept bool   `json:"accept"`
+}
+
+type RequestTakeback struct {
+	GameID string `json:"gameId"`
+}
+
+type RespondTakeback RespondDraw
+
+type OfferRematch struct {
+	GameID string `json:"gameId"`
+}
+
+type AcceptRematch struct {
+	GameID string `json:"gameId"`
+}
+
+type DeclineRematch struct {
+	GameID string `json:"gameId"`
+}
+
+type RequestHint struct {
+	GameID string `json:"gameId"`
+}
+
+// Resync asks for a fresh game_state, e.g. after spotting a gap in seq.
+type Resync struct {
+	GameID string `json:"gameId"`
+}
+
+func (*Hello) MessageType() string           { return "hello" }
+func (*JoinGame) MessageType() string        { return "join_game" }
+func (*RejoinGame) MessageType() string      { return "rejoin_game" }
+func (*MakeMove) MessageType() string        { return "make_move" }
+func (*CreateRoom) MessageType() string      { return "create_room" }
+func (*JoinRoom) MessageType() string        { return "join_room" }
+func (*SpectateGame) MessageType() string    { return "spectate_game" }
+func (*Resign) MessageType() string          { return "resign" }
+func (*OfferDraw) MessageType() string       { return "offer_draw" }
+func (*RespondDraw) MessageType() string     { return "respond_draw" }
+func (*RequestTakeback) MessageType() string { return "request_takeback" }
+func (*RespondTakeback) MessageType() string { return "respond_takeback" }
+func (*OfferRematch) MessageType() string    { return "offer_rematch" }
+func (*AcceptRematch) MessageType() string   { return "accept_rematch" }
+func (*DeclineRematch) MessageType() string  { return "decline_rematch" }
+func (*RequestHint) MessageType() string     { return "request_hint" }
+func (*Resync) MessageType() string          { return "resync" }
+
+var (
+	errMissingGameID = errors.New("gameId is required")
+	errMissingColumn = errors.New("column is required")
+	errColumnRange   = errors.New("column must be between 0 and 6")
+	errNegativeSeq   = errors.New("seq can't be negative")
+)
+
+func (m *Hello) Validate() error {
+	if m.Version < 1 {
+		return errors.New("version is required")
+	}
+	return nil
+}
+
+func (m *MakeMove) Validate() error {
+	if m.GameID == "" {
+		return errMissingGameID
+	}
+	if m.Column == nil {
+		return errMissingColumn
+	}
+	if *m.Column < 0 || *m.Column > 6 {
+		return errColumnRange
+	}
+	if m.Seq != nil && *m.Seq < 0 {
+		return errNegativeSeq
+	}
+	return nil
+}
+
+func (m *RejoinGame) Validate() error {
+	if m.SessionToken == "" {
+		return errors.New("sessionToken is required")
+	}
+	return nil
+}
+
+func (m *SpectateGame) Validate() error    { return requireGameID(m.GameID) }
+func (m *Resign) Validate() error          { return requireGameID(m.GameID) }
+func (m *OfferDraw) Validate() error       { return requireGameID(m.GameID) }
+func (m *RespondDraw) Validate() error     { return requireGameID(m.GameID) }
+func (m *RequestTakeback) Validate() error { return requireGameID(m.GameID) }
+func (m *RespondTakeback) Validate() error { return requireGameID(m.GameID) }
+func (m *OfferRematch) Validate() error    { return requireGameID(m.GameID) }
+func (m *AcceptRematch) Validate() error   { return requireGameID(m.GameID) }
+func (m *DeclineRematch) Validate() error  { return requireGameID(m.GameID) }
+func (m *RequestHint) Validate() error     { return requireGameID(m.GameID) }
+func (m *Resync) Validate() error          { return requireGameID(m.GameID) }
+
+func requireGameID(gameID string) error {
+	if gameID == "" {
+		return errMissingGameID
+	}
+	return nil
+}
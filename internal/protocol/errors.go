@@ -0,0 +1,34 @@
+package protocol
+
+// Error codes carried by error replies. Message is for people; clients
+// should branch on Code.
+const (
+	ErrBadRequest         = "bad_request"         // malformed frame or invalid fields
+	ErrUnknownType        = "unknown_type"        // no such message type
+	ErrUnsupportedVersion = "unsupported_version" // hello with a version this server can't speak
+	ErrInvalidUsername    = "invalid_username"
+	ErrNotFound           = "not_found"       // game, room, player or offer doesn't exist
+	ErrNotYourTurn        = "not_your_turn"   // includes hints requested on the opponent's turn
+	ErrStaleSeq           = "stale_seq"       // the move was made against an older position; resync
+	ErrInvalidMove        = "invalid_move"    // full column
+	ErrGameNotActive      = "game_not_active" // the game has finished or hasn't started
+	ErrForbidden          = "forbidden"       // not allowed from this connection, e.g. spectators moving
+	ErrConflict           = "conflict"        // already queued, playing or offered
+	ErrBusy               = "busy"            // try again shortly
+	ErrInternal           = "internal"
+)
+
+// Error is the data of an error reply.
+type Error struct {
+	Code      string `json:"code" jsonschema:"enum=bad_request|unknown_type|unsupported_version|invalid_username|not_found|not_your_turn|stale_seq|invalid_move|game_not_active|forbidden|conflict|busy|internal"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"` // of the request that failed
+}
+
+func NewError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) MessageType() string { return "error" }
+
+func (e *Error) Error() string { return e.Message }
@@ -0,0 +1,112 @@
+// Package protocol defines the messages exchanged over the game WebSocket.
+//
+// Every frame is a JSON envelope:
+//
+//	{"type": "make_move", "requestId": "42", "data": {"gameId": "...", "column": 3}}
+//
+// requestId is optional and chosen by the client; errors caused by a request
+// echo it back. Schema() describes every message as JSON Schema.
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Protocol versions this server speaks. Clients announce theirs with hello;
+// clients that never do are assumed to speak MinVersion.
+const (
+	Version    = 1
+	MinVersion = 1
+)
+
+// Message is the payload of one envelope, client or server bound.
+type Message interface {
+	MessageType() string
+}
+
+// Envelope is a frame as received from a client, before its data is decoded.
+type Envelope struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"requestId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+type outgoing struct {
+	Type     string  `json:"type"`
+	EventSeq int     `json:"eventSeq,omitempty"`
+	Data     Message `json:"data"`
+}
+
+// validator is implemented by client messages with required fields or
+// ranges that JSON decoding alone doesn't enforce.
+type validator interface {
+	Validate() error
+}
+
+var clientTypes = messageTypes(clientMessages)
+
+func messageTypes(messages []Message) map[string]reflect.Type {
+	types := make(map[string]reflect.Type, len(messages))
+	for _, msg := range messages {
+		types[msg.MessageType()] = reflect.TypeOf(msg).Elem()
+	}
+	return types
+}
+
+// Parse decodes a client frame into its typed message. The envelope is
+// returned even on error so the reply can carry the request ID.
+func Parse(raw []byte) (Envelope, Message, *Error) {
+	var env Envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return env, nil, NewError(ErrBadRequest, "Malformed message")
+	}
+
+	t, ok := clientTypes[env.Type]
+	if !ok {
+		return env, nil, env.Error(ErrUnknownType, fmt.Sprintf("Unknown message type %q", env.Type))
+	}
+
+	msg := reflect.New(t).Interface().(Message)
+	data := env.Data
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		data = []byte("{}")
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return env, nil, env.Error(ErrBadRequest, fmt.Sprintf("Invalid %s: %v", env.Type, err))
+	}
+	if v, ok := msg.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return env, nil, env.Error(ErrBadRequest, err.Error())
+		}
+	}
+	return env, msg, nil
+}
+
+// Error builds an error reply to this envelope.
+func (env Envelope) Error(code, message string) *Error {
+	return &Error{Code: code, Message: message, RequestID: env.RequestID}
+}
+
+// Encode wraps a server message in its envelope.
+func Encode(msg Message) ([]byte, error) {
+	return json.Marshal(outgoing{Type: msg.MessageType(), Data: msg})
+}
+
+// EncodeEvent wraps a game event in its envelope along with its position in
+// the game's event log, which clients hand back when they reconnect.
+func EncodeEvent(msg Message, eventSeq int) ([]byte, error) {
+	return json.Marshal(outgoing{Type: msg.MessageType(), EventSeq: eventSeq, Data: msg})
+}
+
+// Negotiate picks the version to speak with a client that supports up to
+// clientVersion.
+func Negotiate(clientVersion int) (int, *Error) {
+	if clientVersion < MinVersion {
+		return 0, NewError(ErrUnsupportedVersion,
+			fmt.Sprintf("Protocol version %d is no longer supported, minimum is %d", clientVersion, MinVersion))
+	}
+	return min(clientVersion, Version), nil
+}
@@ -0,0 +1,154 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// validMessages has a well-formed data payload for every client message.
+var validMessages = map[string]string{
+	"hello":            `{"version": 1, "client": "test"}`,
+	"join_game":        `{"username": "alice", "difficulty": "strong"}`,
+	"rejoin_game":      `{"sessionToken": "token", "lastEventSeq": 3}`,
+	"make_move":        `{"gameId": "g1", "column": 0, "seq": 2}`,
+	"create_room":      `{"username": "alice", "firstMove": "random", "timeControl": "3+2"}`,
+	"join_room":        `{"username": "bob", "code": "ABC234"}`,
+	"spectate_game":    `{"gameId": "g1"}`,
+	"resign":           `{"gameId": "g1"}`,
+	"offer_draw":       `{"gameId": "g1"}`,
+	"respond_draw":     `{"gameId": "g1", "accept": true}`,
+	"request_takeback": `{"gameId": "g1"}`,
+	"respond_takeback": `{"gameId": "g1", "accept": false}`,
+	"offer_rematch":    `{"gameId": "g1"}`,
+	"accept_rematch":   `{"gameId": "g1"}`,
+	"decline_rematch":  `{"gameId": "g1"}`,
+	"request_hint":     `{"gameId": "g1"}`,
+	"resync":           `{"gameId": "g1"}`,
+}
+
+func frame(msgType, requestID, data string) []byte {
+	raw, _ := json.Marshal(Envelope{Type: msgType, RequestID: requestID, Data: json.RawMessage(data)})
+	return raw
+}
+
+func TestParseValid(t *testing.T) {
+	for msgType := range clientTypes {
+		if _, ok := validMessages[msgType]; !ok {
+			t.Errorf("no test message for %s", msgType)
+		}
+	}
+
+	for msgType, data := range validMessages {
+		env, msg, perr := Parse(frame(msgType, "r-"+msgType, data))
+		if perr != nil {
+			t.Errorf("%s: %s %s", msgType, perr.Code, perr.Message)
+			continue
+		}
+		if msg.MessageType() != msgType || env.RequestID != "r-"+msgType {
+			t.Errorf("%s parsed as %s with request ID %q", msgType, msg.MessageType(), env.RequestID)
+		}
+	}
+
+	_, msg, _ := Parse(frame("make_move", "", validMessages["make_move"]))
+	move := msg.(*MakeMove)
+	if move.GameID != "g1" || move.Column == nil || *move.Column != 0 || move.Seq == nil || *move.Seq != 2 {
+		t.Errorf("make_move decoded as %+v", move)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		code string
+	}{
+		{"malformed JSON", []byte(`{"type": "resign"`), ErrBadRequest},
+		{"unknown type", frame("castle", "r1", `{}`), ErrUnknownType},
+		{"no type", frame("", "r1", `{}`), ErrUnknownType},
+		{"wrong field type", frame("make_move", "r1", `{"gameId": "g1", "column": "3"}`), ErrBadRequest},
+		{"missing column", frame("make_move", "r1", `{"gameId": "g1"}`), ErrBadRequest},
+		{"null column", frame("make_move", "r1", `{"gameId": "g1", "column": null}`), ErrBadRequest},
+		{"column 7", frame("make_move", "r1", `{"gameId": "g1", "column": 7}`), ErrBadRequest},
+		{"column -1", frame("make_move", "r1", `{"gameId": "g1", "column": -1}`), ErrBadRequest},
+		{"negative seq", frame("make_move", "r1", `{"gameId": "g1", "column": 3, "seq": -1}`), ErrBadRequest},
+		{"missing game ID", frame("resign", "r1", `{}`), ErrBadRequest},
+		{"no data", frame("offer_draw", "r1", ``), ErrBadRequest},
+		{"missing session token", frame("rejoin_game", "r1", `{"lastEventSeq": 1}`), ErrBadRequest},
+		{"missing version", frame("hello", "r1", `{}`), ErrBadRequest},
+	}
+
+	for _, test := range tests {
+		_, msg, perr := Parse(test.raw)
+		if perr == nil {
+			t.Errorf("%s: parsed as %+v", test.name, msg)
+			continue
+		}
+		if perr.Code != test.code {
+			t.Errorf("%s: %s (%s), want %s", test.name, perr.Code, perr.Message, test.code)
+		}
+		// Anything that got as far as an envelope answers with its request ID
+		if test.name != "malformed JSON" && perr.RequestID != "r1" {
+			t.Errorf("%s: error carries request ID %q", test.name, perr.RequestID)
+		}
+	}
+}
+
+func TestErrorEchoesRequestID(t *testing.T) {
+	_, _, perr := Parse(frame("make_move", "42", `{"gameId": "g1", "column": 7}`))
+	raw, err := Encode(perr)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	var reply struct {
+		Type string `json:"type"`
+		Data Error  `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		t.Fatalf("decode %s: %v", raw, err)
+	}
+	if reply.Type != "error" || reply.Data.RequestID != "42" || reply.Data.Code != ErrBadRequest {
+		t.Errorf("error reply %s", raw)
+	}
+}
+
+// schemaTypes lists the message types in one of the schema's envelope
+// unions.
+func schemaTypes(t *testing.T, schema map[string]interface{}, union string) []string {
+	t.Helper()
+	defs := schema["$defs"].(map[string]interface{})
+	var types []string
+	for _, envelope := range defs[union].(map[string]interface{})["oneOf"].([]interface{}) {
+		properties := envelope.(map[string]interface{})["properties"].(map[string]interface{})
+		types = append(types, properties["type"].(map[string]interface{})["const"].(string))
+	}
+	sort.Strings(types)
+	return types
+}
+
+func sortedTypes(messages []Message) []string {
+	var types []string
+	for msgType := range messageTypes(messages) {
+		types = append(types, msgType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func TestSchemaListsEveryMessage(t *testing.T) {
+	schema := Schema()
+
+	// The schema is served as JSON, so it has to survive encoding
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+
+	if got, want := schemaTypes(t, schema, "ClientMessage"), sortedTypes(clientMessages); !reflect.DeepEqual(got, want) {
+		t.Errorf("client messages in the schema %v, want %v", got, want)
+	}
+	if got, want := schemaTypes(t, schema, "ServerMessage"), sortedTypes(serverMessages); !reflect.DeepEqual(got, want) {
+		t.Errorf("server messages in the schema %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,266 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema describes every message as a JSON Schema (draft 2020-12) document
+// generated from the Go types. $defs.ClientMessage and $defs.ServerMessage
+// are unions of the envelopes each side may send, and the document as a
+// whole accepts either.
+//
+// Struct fields are required unless tagged omitempty, and pointers are
+// nullable unless tagged omitempty or jsonschema:"notnull". The jsonschema
+// tag also takes enum=a|b, minimum=n, maximum=n and minLength=n.
+func Schema() map[string]interface{} {
+	g := &schemaGen{defs: map[string]interface{}{}, names: map[reflect.Type]string{}}
+	g.defs["ClientMessage"] = map[string]interface{}{"oneOf": g.envelopes(clientMessages, true)}
+	g.defs["ServerMessage"] = map[string]interface{}{"oneOf": g.envelopes(serverMessages, false)}
+
+	return map[string]interface{}{
+		"$schema":         "https://json-schema.org/draft/2020-12/schema",
+		"title":           "4 in a Row WebSocket protocol",
+		"protocolVersion": Version,
+		"anyOf": []interface{}{
+			ref("ClientMessage"),
+			ref("ServerMessage"),
+		},
+		"$defs": g.defs,
+	}
+}
+
+type schemaGen struct {
+	defs  map[string]interface{}
+	names map[reflect.Type]string
+}
+
+func (g *schemaGen) envelopes(messages []Message, fromClient bool) []interface{} {
+	var envelopes []interface{}
+	for _, msg := range messages {
+		properties := map[string]interface{}{
+			"type": map[string]interface{}{"const": msg.MessageType()},
+			"data": g.schemaFor(reflect.TypeOf(msg).Elem()),
+		}
+		required := []string{"type", "data"}
+		if fromClient {
+			properties["requestId"] = map[string]interface{}{"type": "string"}
+			required = []string{"type"}
+		} else {
+			properties["eventSeq"] = map[string]interface{}{"type": "integer", "minimum": 1}
+		}
+		envelopes = append(envelopes, map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		})
+	}
+	return envelopes
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (g *schemaGen) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return ref(g.define(t))
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.element(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.element(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// element is the schema for a slice item or map value, where pointers
+// marshal as null.
+func (g *schemaGen) element(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		return nullable(g.schemaFor(t))
+	}
+	return g.schemaFor(t)
+}
+
+// define adds a named struct to $defs once and returns its name there.
+func (g *schemaGen) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.defs[name]; taken {
+		name = strings.ReplaceAll(t.String(), ".", "_")
+	}
+	g.names[t] = name
+	g.defs[name] = nil // reserve it, the type may refer to itself
+	g.defs[name] = g.object(t)
+	return name
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	g.fields(t, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *schemaGen) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a name of their own are flattened, as
+		// encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		omitempty := strings.Contains(opts, "omitempty")
+		constraints := parseConstraints(field.Tag.Get("jsonschema"))
+
+		schema := g.schemaFor(field.Type)
+		for keyword, value := range constraints.keywords(field.Type) {
+			schema[keyword] = value
+		}
+		if field.Type.Kind() == reflect.Pointer && !omitempty && !constraints.notNull {
+			schema = nullable(schema)
+		}
+
+		properties[name] = schema
+		if !omitempty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+type constraints struct {
+	enum      []string
+	minimum   *float64
+	maximum   *float64
+	minLength *int
+	notNull   bool
+}
+
+func parseConstraints(tag string) constraints {
+	var c constraints
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "enum":
+			c.enum = strings.Split(value, "|")
+		case "minimum":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				c.minimum = &n
+			}
+		case "maximum":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				c.maximum = &n
+			}
+		case "minLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				c.minLength = &n
+			}
+		case "notnull":
+			c.notNull = true
+		}
+	}
+	return c
+}
+
+// keywords renders the constraints for a field of type t; enum values of
+// numeric fields are numbers.
+func (c constraints) keywords(t reflect.Type) map[string]interface{} {
+	keywords := map[string]interface{}{}
+	if c.enum != nil {
+		values := make([]interface{}, len(c.enum))
+		for i, value := range c.enum {
+			values[i] = value
+			if n, err := strconv.ParseFloat(value, 64); err == nil && numeric(t) {
+				values[i] = n
+			}
+		}
+		keywords["enum"] = values
+	}
+	if c.minimum != nil {
+		keywords["minimum"] = *c.minimum
+	}
+	if c.maximum != nil {
+		keywords["maximum"] = *c.maximum
+	}
+	if c.minLength != nil {
+		keywords["minLength"] = *c.minLength
+	}
+	return keywords
+}
+
+func numeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Struct, reflect.Slice, reflect.Map:
+		return false
+	default:
+		return true
+	}
+}
+
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}},
+	}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
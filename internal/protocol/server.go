@@ -0,0 +1,243 @@
+package protocol
+
+import (
+	"time"
+
+	"emitrr-4-in-a-row/internal/models"
+)
+
+// serverMessages lists everything the server may send, for Schema.
+var serverMessages = []Message{
+	&Welcome{},
+	&Ack{},
+	&Error{},
+	&WaitingForOpponent{},
+	&GameStarted{},
+	&GameRejoined{},
+	&GameState{},
+	&MoveMade{},
+	&GameEnded{},
+	&PlayerDisconnected{},
+	&PlayerReconnected{},
+	&RoomCreated{},
+	&RoomExpired{},
+	&Spectating{},
+	&SpectatorsUpdated{},
+	&RematchOffered{},
+	&RematchDeclined{},
+	&RematchCancelled{},
+	&DrawOffered{},
+	&DrawDeclined{},
+	&TakebackRequested{},
+	&TakebackDeclined{},
+	&Takeback{},
+	&Hint{},
+	&ReplayStarted{},
+	&ReplayEnded{},
+	&Latency{},
+}
+
+// Welcome answers hello with the version both sides will speak.
+type Welcome struct {
+	Version    int `json:"version"`
+	MinVersion int `json:"minVersion"`
+	MaxVersion int `json:"maxVersion"`
+}
+
+// Ack confirms a request that carried a requestId was carried out. Events it
+// caused, such as move_made, are sent as well.
+type Ack struct {
+	RequestID string `json:"requestId"`
+	Type      string `json:"type"`                // of the request
+	Seq       int    `json:"seq,omitempty"`       // make_move only: the game's seq after the move
+	Duplicate bool   `json:"duplicate,omitempty"` // a retry of a request already carried out
+}
+
+// GameState is the reply to resync. YourPlayer is 0 for spectators.
+type GameState struct {
+	GameState  *models.Game  `json:"gameState"`
+	YourPlayer int           `json:"yourPlayer" jsonschema:"enum=0|1|2"`
+	Clock      *models.Clock `json:"clock,omitempty"`
+}
+
+type WaitingForOpponent struct {
+	Rating     float64 `json:"rating"`
+	BotTimeout int     `json:"botTimeout"` // seconds before the bot steps in
+}
+
+// GameStarted carries a sessionToken for taking the seat back with
+// rejoin_game should the connection drop. It is only good for this game.
+type GameStarted struct {
+	GameState    *models.Game `json:"gameState"`
+	YourPlayer   int          `json:"yourPlayer" jsonschema:"enum=1|2"`
+	Difficulty   string       `json:"difficulty,omitempty"` // bot games only
+	SessionToken string       `json:"sessionToken"`
+}
+
+// GameRejoined is the snapshot a reconnecting player gets. With catchUp
+// "events" the events missed since lastEventSeq follow it; with "snapshot"
+// they are gone and gameState is all there is.
+type GameRejoined struct {
+	GameState  *models.Game `json:"gameState"`
+	YourPlayer int          `json:"yourPlayer" jsonschema:"enum=1|2"`
+	EventSeq   int          `json:"eventSeq"` // the latest event gameState includes
+	CatchUp    string       `json:"catchUp" jsonschema:"enum=events|snapshot"`
+}
+
+// MoveMade carries the game's seq after the move. Clients that see seq jump
+// by more than one have missed something and should resync.
+type MoveMade struct {
+	Seq       int           `json:"seq"`
+	Column    int           `json:"column"`
+	Row       int           `json:"row"`
+	Player    int           `json:"player" jsonschema:"enum=1|2"`
+	GameState *models.Game  `json:"gameState"`
+	Clock     *models.Clock `json:"clock,omitempty"`     // timed games only
+	MoveIndex *int          `json:"moveIndex,omitempty"` // replays only
+}
+
+type GameEnded struct {
+	Winner    *int         `json:"winner"` // null for a draw
+	GameState *models.Game `json:"gameState"`
+}
+
+type PlayerDisconnected struct {
+	Player        string        `json:"player"`
+	ReconnectTime int           `json:"reconnectTime"` // seconds they have to come back
+	Clock         *models.Clock `json:"clock,omitempty"`
+}
+
+type PlayerReconnected struct {
+	Player string        `json:"player"`
+	Clock  *models.Clock `json:"clock,omitempty"`
+}
+
+type RoomCreated struct {
+	Code        string              `json:"code"`
+	Creator     string              `json:"creator"`
+	FirstMove   string              `json:"firstMove" jsonschema:"enum=creator|invitee|random"`
+	Rated       bool                `json:"rated"`
+	TimeControl *models.TimeControl `json:"timeControl,omitempty"`
+	ExpiresAt   time.Time           `json:"expiresAt"`
+}
+
+type RoomExpired struct {
+	Code string `json:"code"`
+}
+
+// Spectating is the snapshot a new spectator gets before the live events.
+// CatchUp works as in GameRejoined.
+type Spectating struct {
+	GameState  *models.Game `json:"gameState"`
+	Spectators int          `json:"spectators"`
+	EventSeq   int          `json:"eventSeq"`
+	CatchUp    string       `json:"catchUp" jsonschema:"enum=events|snapshot"`
+}
+
+type SpectatorsUpdated struct {
+	GameID     string `json:"gameId"`
+	Spectators int    `json:"spectators"`
+}
+
+type RematchOffered struct {
+	GameID  string `json:"gameId"`
+	From    string `json:"from"`
+	Timeout int    `json:"timeout"` // seconds to answer
+}
+
+type RematchDeclined struct {
+	GameID string `json:"gameId"`
+	Player string `json:"player"`
+}
+
+type RematchCancelled struct {
+	GameID string `json:"gameId"`
+	Reason string `json:"reason" jsonschema:"enum=timeout|opponent_left"`
+}
+
+type DrawOffered struct {
+	GameID string `json:"gameId"`
+	From   string `json:"from"`
+}
+
+type DrawDeclined struct {
+	GameID string `json:"gameId"`
+	Player string `json:"player"`
+}
+
+type TakebackRequested struct {
+	GameID string `json:"gameId"`
+	From   string `json:"from"`
+}
+
+type TakebackDeclined struct {
+	GameID string `json:"gameId"`
+	Player string `json:"player"`
+}
+
+// Takeback reports moves undone by an accepted takeback request.
+type Takeback struct {
+	Player    int           `json:"player" jsonschema:"enum=1|2"` // who asked
+	Moves     int           `json:"moves"`
+	Seq       int           `json:"seq"`
+	GameState *models.Game  `json:"gameState"`
+	Clock     *models.Clock `json:"clock,omitempty"`
+}
+
+// Hint scores every column for the side to move; ColumnScores is null for
+// full columns.
+type Hint struct {
+	GameID       string     `json:"gameId"`
+	Board        [][]int    `json:"board"`
+	ColumnScores []*float64 `json:"columnScores"`
+	BestMove     int        `json:"bestMove"`
+	Outcome      string     `json:"outcome" jsonschema:"enum=win|loss|draw|unknown"`
+	Plies        int        `json:"plies,omitempty"`
+	Exact        bool       `json:"exact"`
+	Depth        int        `json:"depth,omitempty"`
+	SideToMove   int        `json:"sideToMove" jsonschema:"enum=1|2"`
+}
+
+type ReplayStarted struct {
+	GameID     string       `json:"gameId"`
+	Speed      float64      `json:"speed"`
+	TotalMoves int          `json:"totalMoves"`
+	GameState  *models.Game `json:"gameState"`
+}
+
+type ReplayEnded struct {
+	Winner    *int         `json:"winner"`
+	GameState *models.Game `json:"gameState"`
+}
+
+// Latency reports the round trip of the latest heartbeat ping.
+type Latency struct {
+	RTT int `json:"rtt"` // milliseconds
+}
+
+func (*Welcome) MessageType() string            { return "welcome" }
+func (*Ack) MessageType() string                { return "ack" }
+func (*GameState) MessageType() string          { return "game_state" }
+func (*WaitingForOpponent) MessageType() string { return "waiting_for_opponent" }
+func (*GameStarted) MessageType() string        { return "game_started" }
+func (*GameRejoined) MessageType() string       { return "game_rejoined" }
+func (*MoveMade) MessageType() string           { return "move_made" }
+func (*GameEnded) MessageType() string          { return "game_ended" }
+func (*PlayerDisconnected) MessageType() string { return "player_disconnected" }
+func (*PlayerReconnected) MessageType() string  { return "player_reconnected" }
+func (*RoomCreated) MessageType() string        { return "room_created" }
+func (*RoomExpired) MessageType() string        { return "room_expired" }
+func (*Spectating) MessageType() string         { return "spectating" }
+func (*SpectatorsUpdated) MessageType() string  { return "spectators_updated" }
+func (*RematchOffered) MessageType() string     { return "rematch_offered" }
+func (*RematchDeclined) MessageType() string    { return "rematch_declined" }
+func (*RematchCancelled) MessageType() string   { return "rematch_cancelled" }
+func (*DrawOffered) MessageType() string        { return "draw_offered" }
+func (*DrawDeclined) MessageType() string       { return "draw_declined" }
+func (*TakebackRequested) MessageType() string  { return "takeback_requested" }
+func (*TakebackDeclined) MessageType() string   { return "takeback_declined" }
+func (*Takeback) MessageType() string           { return "takeback" }
+func (*Hint) MessageType() string               { return "hint" }
+func (*ReplayStarted) MessageType() string      { return "replay_started" }
+func (*ReplayEnded) MessageType() string        { return "replay_ended" }
+func (*Latency) MessageType() string            { return "latency" }
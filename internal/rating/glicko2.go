@@ -0,0 +1,143 @@
+// Package rating implements the Glicko-2 rating system
+// (http://www.glicko.net/glicko/glicko2.pdf). Every finished game is treated
+// as its own rating period.
+package rating
+
+import "math"
+
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	// Players whose deviation is above this are still provisional
+	ProvisionalDeviation = 110.0
+
+	tau         = 0.5 // constrains volatility changes
+	scale       = 173.7178
+	convergence = 0.000001
+)
+
+// Game results from the rated player's point of view
+const (
+	Win  = 1.0
+	Draw = 0.5
+	Loss = 0.0
+)
+
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+func Default() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+func (r Rating) Provisional() bool {
+	return r.Deviation > ProvisionalDeviation
+}
+
+// Update returns the rating after one rating period containing results. With
+// no results only the deviation grows, reflecting the added uncertainty.
+func (r Rating) Update(results ...Result) Rating {
+	mu := (r.Rating - DefaultRating) / scale
+	phi := r.Deviation / scale
+	sigma := r.Volatility
+	if sigma <= 0 {
+		sigma = DefaultVolatility
+	}
+
+	if len(results) == 0 {
+		return Rating{
+			Rating:     r.Rating,
+			Deviation:  math.Min(math.Sqrt(phi*phi+sigma*sigma)*scale, DefaultDeviation),
+			Volatility: sigma,
+		}
+	}
+
+	// Estimated variance and improvement from the game outcomes
+	var invV, sum float64
+	for _, result := range results {
+		muJ := (result.Opponent.Rating - DefaultRating) / scale
+		phiJ := result.Opponent.Deviation / scale
+		gJ := g(phiJ)
+		e := expected(mu, muJ, gJ)
+		invV += gJ * gJ * e * (1 - e)
+		sum += gJ * (result.Score - e)
+	}
+	v := 1 / invV
+	delta := v * sum
+
+	sigma = newVolatility(sigma, phi, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*sum
+
+	return Rating{
+		Rating:     muNew*scale + DefaultRating,
+		Deviation:  math.Min(phiNew*scale, DefaultDeviation),
+		Volatility: sigma,
+	}
+}
+
+// ExpectedScore predicts r's score against opponent, allowing for the
+// uncertainty in both ratings.
+func (r Rating) ExpectedScore(opponent Rating) float64 {
+	mu := (r.Rating - DefaultRating) / scale
+	muJ := (opponent.Rating - DefaultRating) / scale
+	phi := math.Hypot(r.Deviation, opponent.Deviation) / scale
+	return expected(mu, muJ, g(phi))
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muJ, gJ float64) float64 {
+	return 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm
+// (step 5 of the paper).
+func newVolatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > convergence {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,47 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+// TestGlickmanExample reproduces the worked example in section 3 of the
+// Glicko-2 paper.
+func TestGlickmanExample(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := player.Update(
+		Result{Opponent: Rating{Rating: 1400, Deviation: 30}, Score: Win},
+		Result{Opponent: Rating{Rating: 1550, Deviation: 100}, Score: Loss},
+		Result{Opponent: Rating{Rating: 1700, Deviation: 300}, Score: Loss},
+	)
+
+	if math.Abs(got.Rating-1464.06) > 0.01 {
+		t.Errorf("rating %.4f, want 1464.06", got.Rating)
+	}
+	if math.Abs(got.Deviation-151.52) > 0.01 {
+		t.Errorf("deviation %.4f, want 151.52", got.Deviation)
+	}
+	if math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Errorf("volatility %.6f, want 0.05999", got.Volatility)
+	}
+}
+
+func TestUpdateWithoutResults(t *testing.T) {
+	for _, deviation := range []float64{30, 200, 349.9, DefaultDeviation} {
+		r := Rating{Rating: 1620, Deviation: deviation, Volatility: DefaultVolatility}
+		got := r.Update()
+
+		if got.Rating != r.Rating || got.Volatility != r.Volatility {
+			t.Errorf("deviation %v: %+v, only the deviation should change", deviation, got)
+		}
+		if got.Deviation > DefaultDeviation {
+			t.Errorf("deviation %v grew to %v, above the cap of %v", deviation, got.Deviation, DefaultDeviation)
+		}
+		if deviation < DefaultDeviation && got.Deviation <= deviation {
+			t.Errorf("deviation %v did not grow: %v", deviation, got.Deviation)
+		}
+		if deviation == DefaultDeviation && got.Deviation != DefaultDeviation {
+			t.Errorf("deviation at the cap moved to %v", got.Deviation)
+		}
+	}
+}
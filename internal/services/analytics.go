@@ -12,11 +12,11 @@ import (
 )
 
 type AnalyticsService struct {
-	cfg         *config.Config
-	redisClient *redis.Client
+	cfg          *config.Config
+	redisClient  *redis.Client
 	kafkaService *KafkaService
-	useKafka    bool
-	initialized bool
+	useKafka     bool
+	initialized  bool
 }
 
 type AnalyticsEvent struct {
@@ -96,7 +96,7 @@ func (as *AnalyticsService) TrackEvent(eventType string, data map[string]interfa
 	case "game_started":
 		log.Printf("Game started: %v at %s", data["gameId"], event.Timestamp)
 	case "game_ended":
-		log.Printf("Game ended: %v, Winner: %v, Duration: %v", 
+		log.Printf("Game ended: %v, Winner: %v, Duration: %v",
 			data["gameId"], data["winner"], data["duration"])
 	case "move_made":
 		log.Printf("Move tracked: Game %v, Column %v", data["gameId"], data["column"])
@@ -120,7 +120,7 @@ func (as *AnalyticsService) publishToRedis(event AnalyticsEvent) {
 	}
 }
 
-func (as *AnalyticsService) StartConsumer(dbService *DatabaseService) error {
+func (as *AnalyticsService) StartConsumer(dbService Storage) error {
 	if !as.initialized {
 		log.Println("Analytics service not initialized, skipping consumer")
 		return nil
@@ -137,7 +137,7 @@ func (as *AnalyticsService) StartConsumer(dbService *DatabaseService) error {
 	return as.startRedisConsumer(dbService)
 }
 
-func (as *AnalyticsService) startRedisConsumer(dbService *DatabaseService) error {
+func (as *AnalyticsService) startRedisConsumer(dbService Storage) error {
 	log.Println("Starting Redis analytics consumer")
 
 	go func() {
@@ -168,7 +168,7 @@ func (as *AnalyticsService) startRedisConsumer(dbService *DatabaseService) error
 	return nil
 }
 
-func (as *AnalyticsService) processEvent(event AnalyticsEvent, dbService *DatabaseService) {
+func (as *AnalyticsService) processEvent(event AnalyticsEvent, dbService Storage) {
 	switch event.EventType {
 	case "game_started":
 		as.trackGameStart(event.Data, event.Timestamp)
@@ -185,8 +185,8 @@ func (as *AnalyticsService) trackGameStart(data map[string]interface{}, timestam
 	log.Printf("Game started: %v at %s", data["gameId"], timestamp)
 }
 
-func (as *AnalyticsService) trackGameEnd(data map[string]interface{}, timestamp string, dbService *DatabaseService) {
-	log.Printf("Game ended: %v, Winner: %v, Duration: %v", 
+func (as *AnalyticsService) trackGameEnd(data map[string]interface{}, timestamp string, dbService Storage) {
+	log.Printf("Game ended: %v, Winner: %v, Duration: %v",
 		data["gameId"], data["winner"], data["duration"])
 
 	if dbService != nil {
@@ -221,4 +221,4 @@ func getPlayerFromData(data map[string]interface{}) interface{} {
 		return winner
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -2,37 +2,113 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"emitrr-4-in-a-row/internal/config"
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/rating"
 
 	_ "github.com/lib/pq"
 )
 
+// DatabaseService is the Postgres Storage backend.
 type DatabaseService struct {
 	db  *sql.DB
 	cfg *config.Config
 }
 
 type GameData struct {
-	ID        string
-	Player1   string
-	Player2   string
-	Winner    *int
-	Duration  int
-	Moves     int
-	IsBot     bool
-	CreatedAt time.Time
+	ID            string
+	Player1       string
+	Player2       string
+	Winner        *int
+	Duration      int
+	Moves         int
+	IsBot         bool
+	BotDifficulty string
+	BotSeat       int // 1 or 2 in bot games
+	EndReason     string
+	MoveHistory   []models.Move
+	CreatedAt     time.Time
+	Results       []PlayerResult
+}
+
+// Game results from a player's point of view
+const (
+	ResultWin  = "win"
+	ResultLoss = "loss"
+	ResultDraw = "draw"
+)
+
+// PlayerResult is one human player's outcome of a finished game. Rated
+// results also move the player's PvP or vs-bot rating: against Opponent's
+// stored rating, or against OpponentRating for bots.
+type PlayerResult struct {
+	Username       string
+	Result         string
+	VsBot          bool
+	Abandoned      bool
+	Rated          bool
+	Opponent       string
+	OpponentRating rating.Rating
+}
+
+type GameRecord struct {
+	ID            string        `json:"id"`
+	Player1       string        `json:"player1"`
+	Player2       string        `json:"player2"`
+	Winner        *int          `json:"winner"`
+	Duration      int           `json:"duration"`
+	Moves         int           `json:"moves"`
+	IsBot         bool          `json:"isBot"`
+	BotDifficulty string        `json:"botDifficulty,omitempty"`
+	BotSeat       int           `json:"botSeat,omitempty"`
+	EndReason     string        `json:"endReason,omitempty"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	FinishedAt    *time.Time    `json:"finishedAt"`
+	MoveHistory   []models.Move `json:"moveHistory"`
 }
 
 type PlayerStats struct {
-	Username    string  `json:"username"`
-	GamesPlayed int     `json:"games_played"`
-	GamesWon    int     `json:"games_won"`
-	WinRate     float64 `json:"win_rate"`
-	LastPlayed  string  `json:"last_played"`
+	Username      string  `json:"username"`
+	GamesPlayed   int     `json:"games_played"`
+	GamesWon      int     `json:"games_won"`
+	GamesLost     int     `json:"games_lost"`
+	GamesDrawn    int     `json:"games_drawn"`
+	GamesVsBot    int     `json:"games_vs_bot"`
+	GamesVsHuman  int     `json:"games_vs_human"`
+	Abandonments  int     `json:"abandonments"`
+	CurrentStreak int     `json:"current_streak"`
+	BestStreak    int     `json:"best_streak"`
+	TotalDuration int     `json:"total_duration"`
+	WinRate       float64 `json:"win_rate"`
+	LastPlayed    string  `json:"last_played"`
+	BotDifficulty string  `json:"bot_difficulty,omitempty"`
+
+	Rating             float64 `json:"rating,omitempty"`
+	RatingDeviation    float64 `json:"rating_deviation,omitempty"`
+	Provisional        bool    `json:"provisional,omitempty"`
+	BotRating          float64 `json:"bot_rating,omitempty"`
+	BotRatingDeviation float64 `json:"bot_rating_deviation,omitempty"`
+	BotProvisional     bool    `json:"bot_provisional,omitempty"`
+}
+
+type GameReviewData struct {
+	GameID          string
+	Player1         string
+	Player2         string
+	Player1Accuracy *float64
+	Player2Accuracy *float64
+	Review          interface{}
+}
+
+type PlayerAccuracy struct {
+	Username      string  `json:"username"`
+	GamesReviewed int     `json:"games_reviewed"`
+	AvgAccuracy   float64 `json:"avg_accuracy"`
+	BestAccuracy  float64 `json:"best_accuracy"`
 }
 
 type Analytics struct {
@@ -42,13 +118,22 @@ type Analytics struct {
 	GamesPerDay     []map[string]interface{} `json:"gamesPerDay"`
 	TopWinners      []map[string]interface{} `json:"topWinners"`
 	BotVsHuman      []map[string]interface{} `json:"botVsHuman"`
+	EndReasons      []map[string]interface{} `json:"endReasons"`
 }
 
 func NewDatabaseService(cfg *config.Config) *DatabaseService {
 	return &DatabaseService{cfg: cfg}
 }
 
+// Initialize connects to the database and applies any pending migrations.
 func (ds *DatabaseService) Initialize() error {
+	if err := ds.Connect(); err != nil {
+		return err
+	}
+	return ds.Migrate()
+}
+
+func (ds *DatabaseService) Connect() error {
 	var connStr string
 	if ds.cfg.DatabaseURL != "" {
 		connStr = ds.cfg.DatabaseURL
@@ -67,275 +152,127 @@ func (ds *DatabaseService) Initialize() error {
 	}
 
 	ds.db = db
-	return ds.createTables()
+	return nil
 }
 
-func (ds *DatabaseService) createTables() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS games (
-			id VARCHAR(36) PRIMARY KEY,
-			player1 VARCHAR(100) NOT NULL,
-			player2 VARCHAR(100) NOT NULL,
-			winner INTEGER,
-			duration INTEGER NOT NULL,
-			moves INTEGER NOT NULL,
-			is_bot BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			finished_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS players (
-			username VARCHAR(100) PRIMARY KEY,
-			games_played INTEGER DEFAULT 0,
-			games_won INTEGER DEFAULT 0,
-			total_duration INTEGER DEFAULT 0,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			last_played TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS analytics_events (
-			id SERIAL PRIMARY KEY,
-			event_type VARCHAR(50) NOT NULL,
-			game_id VARCHAR(36),
-			player VARCHAR(100),
-			data JSONB,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_games_created_at ON games(created_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_players_games_won ON players(games_won DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_analytics_events_type ON analytics_events(event_type)`,
-		`CREATE INDEX IF NOT EXISTS idx_analytics_events_created_at ON analytics_events(created_at)`,
-	}
+func (ds *DatabaseService) Migrate() error {
+	return ds.migrator().up()
+}
 
-	for _, query := range queries {
-		if _, err := ds.db.Exec(query); err != nil {
-			return err
-		}
-	}
+func (ds *DatabaseService) MigrateDown(steps int) error {
+	return ds.migrator().down(steps)
+}
 
-	log.Println("Database tables created successfully")
-	return nil
+func (ds *DatabaseService) MigrationStatus() ([]MigrationStatus, error) {
+	return ds.migrator().status()
+}
+
+func (ds *DatabaseService) migrator() *migrator {
+	return &migrator{db: ds.db, dialect: "postgres", lock: postgresMigrationLock}
 }
 
 func (ds *DatabaseService) SaveGame(gameData GameData) error {
-	if ds.db == nil {
-		return fmt.Errorf("database not initialized")
-	}
+	return saveGame(ds.db, gameData)
+}
 
+// GetGame returns a finished game with its full move history, or nil if unknown.
+func (ds *DatabaseService) GetGame(gameID string) (*GameRecord, error) {
+	return getGame(ds.db, gameID)
+}
+
+func (ds *DatabaseService) GetPlayerStats(username string) (*PlayerStats, error) {
 	query := `
-		INSERT INTO games (id, player1, player2, winner, duration, moves, is_bot, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		SELECT 
+			username, games_played, games_won, games_lost, games_drawn,
+			games_vs_bot, games_vs_human, abandonments, current_streak, best_streak, total_duration,
+			ROUND((games_won::DECIMAL / GREATEST(games_played, 1)) * 100, 1) as win_rate,
+			last_played,
+			pvp_rating, pvp_rating_deviation, bot_rating, bot_rating_deviation
+		FROM players 
+		WHERE username = $1
 	`
 
-	_, err := ds.db.Exec(query,
-		gameData.ID,
-		gameData.Player1,
-		gameData.Player2,
-		gameData.Winner,
-		gameData.Duration,
-		gameData.Moves,
-		gameData.IsBot,
-		gameData.CreatedAt,
-	)
-
-	return err
+	return getPlayerStats(ds.db, query, username)
 }
 
-func (ds *DatabaseService) UpdatePlayerStats(username string, won bool) error {
-	if ds.db == nil {
-		return fmt.Errorf("database not initialized")
-	}
-
-	wonInt := 0
-	if won {
-		wonInt = 1
-	}
-
-	query := `
-		INSERT INTO players (username, games_played, games_won, last_played)
-		VALUES ($1, 1, $2, CURRENT_TIMESTAMP)
-		ON CONFLICT (username) 
-		DO UPDATE SET 
-			games_played = players.games_played + 1,
-			games_won = players.games_won + $2,
-			last_played = CURRENT_TIMESTAMP
-	`
+func (ds *DatabaseService) SaveGameReview(review GameReviewData) error {
+	return saveGameReview(ds.db, review)
+}
 
-	_, err := ds.db.Exec(query, username, wonInt)
-	return err
+// GetGameReview returns the stored review JSON, or nil if the game has none.
+func (ds *DatabaseService) GetGameReview(gameID string) (json.RawMessage, error) {
+	return getGameReview(ds.db, gameID)
 }
 
-func (ds *DatabaseService) GetLeaderboard(limit int) ([]PlayerStats, error) {
-	if ds.db == nil {
-		return []PlayerStats{}, nil
-	}
+func (ds *DatabaseService) GetPlayerAccuracy(username string) (PlayerAccuracy, error) {
+	return getPlayerAccuracy(ds.db, username)
+}
 
+// GetLeaderboard ranks players by PvP rating, vs-bot rating or raw wins.
+func (ds *DatabaseService) GetLeaderboard(sort string, limit int) ([]PlayerStats, error) {
+	where, order := leaderboardOrder(sort)
 	query := `
 		SELECT 
-			username,
-			games_played,
-			games_won,
+			username, games_played, games_won, games_lost, games_drawn,
+			games_vs_bot, games_vs_human, abandonments, current_streak, best_streak, total_duration,
 			ROUND((games_won::DECIMAL / GREATEST(games_played, 1)) * 100, 1) as win_rate,
-			last_played
+			last_played,
+			pvp_rating, pvp_rating_deviation, bot_rating, bot_rating_deviation
 		FROM players 
-		WHERE games_played > 0
-		ORDER BY games_won DESC, win_rate DESC, games_played DESC
+		WHERE ` + where + `
+		ORDER BY ` + order + `
 		LIMIT $1
 	`
 
-	rows, err := ds.db.Query(query, limit)
-	if err != nil {
-		return []PlayerStats{}, err
-	}
-	defer rows.Close()
-
-	var leaderboard []PlayerStats
-	for rows.Next() {
-		var stats PlayerStats
-		var lastPlayed time.Time
-		err := rows.Scan(&stats.Username, &stats.GamesPlayed, &stats.GamesWon, &stats.WinRate, &lastPlayed)
-		if err != nil {
-			continue
-		}
-		stats.LastPlayed = lastPlayed.Format("2006-01-02 15:04:05")
-		leaderboard = append(leaderboard, stats)
-	}
-
-	return leaderboard, nil
+	return queryLeaderboard(ds.db, query, limit)
 }
 
-func (ds *DatabaseService) GetAnalytics() (Analytics, error) {
-	analytics := Analytics{}
-
-	if ds.db == nil {
-		return analytics, nil
-	}
-
-	queries := map[string]string{
-		"totalGames":      "SELECT COUNT(*) as count FROM games",
-		"totalPlayers":    "SELECT COUNT(*) as count FROM players WHERE games_played > 0",
-		"avgGameDuration": "SELECT ROUND(AVG(duration), 1) as avg_duration FROM games",
-		"gamesPerDay": `
-			SELECT 
-				DATE(created_at) as date,
-				COUNT(*) as games
-			FROM games 
-			WHERE created_at >= CURRENT_DATE - INTERVAL '7 days'
-			GROUP BY DATE(created_at)
-			ORDER BY date DESC
-		`,
-		"topWinners": `
-			SELECT username, games_won 
-			FROM players 
-			WHERE games_played > 0
-			ORDER BY games_won DESC 
-			LIMIT 5
-		`,
-		"botVsHuman": `
-			SELECT 
-				is_bot,
-				COUNT(*) as count,
-				ROUND(AVG(duration), 1) as avg_duration
-			FROM games 
-			GROUP BY is_bot
-		`,
-	}
-
-	// Total Games
-	if rows, err := ds.db.Query(queries["totalGames"]); err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var count int
-			rows.Scan(&count)
-			analytics.TotalGames = append(analytics.TotalGames, map[string]interface{}{"count": count})
-		}
-	}
-
-	// Total Players
-	if rows, err := ds.db.Query(queries["totalPlayers"]); err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var count int
-			rows.Scan(&count)
-			analytics.TotalPlayers = append(analytics.TotalPlayers, map[string]interface{}{"count": count})
-		}
-	}
-
-	// Average Game Duration
-	if rows, err := ds.db.Query(queries["avgGameDuration"]); err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var avgDuration sql.NullFloat64
-			rows.Scan(&avgDuration)
-			if avgDuration.Valid {
-				analytics.AvgGameDuration = append(analytics.AvgGameDuration, map[string]interface{}{"avg_duration": avgDuration.Float64})
-			}
-		}
-	}
-
-	// Games Per Day
-	if rows, err := ds.db.Query(queries["gamesPerDay"]); err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var date time.Time
-			var games int
-			rows.Scan(&date, &games)
-			analytics.GamesPerDay = append(analytics.GamesPerDay, map[string]interface{}{
-				"date":  date.Format("2006-01-02"),
-				"games": games,
-			})
-		}
-	}
-
-	// Top Winners
-	if rows, err := ds.db.Query(queries["topWinners"]); err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var username string
-			var gamesWon int
-			rows.Scan(&username, &gamesWon)
-			analytics.TopWinners = append(analytics.TopWinners, map[string]interface{}{
-				"username":  username,
-				"games_won": gamesWon,
-			})
-		}
-	}
+// GetBotLeaderboard ranks human players by their results against a single bot level.
+func (ds *DatabaseService) GetBotLeaderboard(difficulty string, limit int) ([]PlayerStats, error) {
+	query := `
+		SELECT 
+			CASE WHEN bot_seat = 1 THEN player2 ELSE player1 END as player,
+			COUNT(*) as games_played,
+			COUNT(*) FILTER (WHERE winner <> bot_seat) as games_won,
+			COUNT(*) FILTER (WHERE winner = bot_seat) as games_lost,
+			COUNT(*) FILTER (WHERE winner IS NULL) as games_drawn,
+			COUNT(*) as games_vs_bot,
+			0 as games_vs_human,
+			0 as abandonments,
+			0 as current_streak,
+			0 as best_streak,
+			SUM(duration) as total_duration,
+			ROUND((COUNT(*) FILTER (WHERE winner <> bot_seat))::DECIMAL / COUNT(*) * 100, 1) as win_rate,
+			MAX(finished_at) as last_played,
+			NULL, NULL, NULL, NULL
+		FROM games 
+		WHERE is_bot = TRUE AND bot_difficulty = $1
+		GROUP BY player
+		ORDER BY games_won DESC, win_rate DESC, games_played DESC
+		LIMIT $2
+	`
 
-	// Bot vs Human
-	if rows, err := ds.db.Query(queries["botVsHuman"]); err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var isBot bool
-			var count int
-			var avgDuration sql.NullFloat64
-			rows.Scan(&isBot, &count, &avgDuration)
-			analytics.BotVsHuman = append(analytics.BotVsHuman, map[string]interface{}{
-				"is_bot":       isBot,
-				"count":        count,
-				"avg_duration": avgDuration.Float64,
-			})
-		}
+	leaderboard, err := queryLeaderboard(ds.db, query, difficulty, limit)
+	for i := range leaderboard {
+		leaderboard[i].BotDifficulty = difficulty
 	}
+	return leaderboard, err
+}
 
-	return analytics, nil
+func (ds *DatabaseService) GetAnalytics() (Analytics, error) {
+	return getAnalytics(ds.db, `
+		SELECT 
+			DATE(created_at) as date,
+			COUNT(*) as games
+		FROM games 
+		WHERE created_at >= CURRENT_DATE - INTERVAL '7 days'
+		GROUP BY DATE(created_at)
+		ORDER BY date DESC
+	`)
 }
 
 func (ds *DatabaseService) SaveAnalyticsEvent(eventType, gameID, player string, data map[string]interface{}) error {
-	if ds.db == nil {
-		return nil
-	}
-
-	query := `
-		INSERT INTO analytics_events (event_type, game_id, player, data)
-		VALUES ($1, $2, $3, $4)
-	`
-
-	dataJSON := "{}"
-	if data != nil {
-		// Simple JSON marshaling - in production, use proper JSON library
-		dataJSON = fmt.Sprintf("%v", data)
-	}
-
-	_, err := ds.db.Exec(query, eventType, gameID, player, dataJSON)
-	return err
+	return saveAnalyticsEvent(ds.db, eventType, gameID, player, data)
 }
 
 func (ds *DatabaseService) Close() error {
@@ -343,4 +280,4 @@ func (ds *DatabaseService) Close() error {
 		return ds.db.Close()
 	}
 	return nil
-}
\ No newline at end of file
+}
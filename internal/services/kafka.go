@@ -10,7 +10,7 @@ import (
 )
 
 type KafkaService struct {
-	writer *kafka.Writer
+	writer  *kafka.Writer
 	enabled bool
 }
 
@@ -26,17 +26,17 @@ func NewKafkaService(brokerURL string) *KafkaService {
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	
+
 	testMsg := kafka.Message{Key: []byte("test"), Value: []byte("test")}
 	err := writer.WriteMessages(ctx, testMsg)
 	enabled := err == nil
-	
+
 	if !enabled {
 		log.Printf("Kafka unavailable, analytics disabled: %v", err)
 	}
 
 	return &KafkaService{
-		writer: writer,
+		writer:  writer,
 		enabled: enabled,
 	}
 }
@@ -45,7 +45,7 @@ func (k *KafkaService) PublishEvent(event AnalyticsEvent) error {
 	if !k.enabled {
 		return nil // Silently skip if Kafka unavailable
 	}
-	
+
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return err
@@ -76,7 +76,7 @@ func (k *KafkaService) StartConsumer(processor func(AnalyticsEvent)) {
 		log.Println("Kafka consumer disabled")
 		return
 	}
-	
+
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:  []string{k.writer.Addr.String()},
 		Topic:    "game-analytics",
@@ -123,4 +123,4 @@ func (k *KafkaService) Close() {
 	if k.writer != nil {
 		k.writer.Close()
 	}
-}
\ No newline at end of file
+}
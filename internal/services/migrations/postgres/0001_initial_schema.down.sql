@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS analytics_events;
+DROP TABLE IF EXISTS players;
+DROP TABLE IF EXISTS games;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS games (
+	id VARCHAR(36) PRIMARY KEY,
+	player1 VARCHAR(100) NOT NULL,
+	player2 VARCHAR(100) NOT NULL,
+	winner INTEGER,
+	duration INTEGER NOT NULL,
+	moves INTEGER NOT NULL,
+	is_bot BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	finished_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS players (
+	username VARCHAR(100) PRIMARY KEY,
+	games_played INTEGER DEFAULT 0,
+	games_won INTEGER DEFAULT 0,
+	total_duration INTEGER DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_played TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS analytics_events (
+	id SERIAL PRIMARY KEY,
+	event_type VARCHAR(50) NOT NULL,
+	game_id VARCHAR(36),
+	player VARCHAR(100),
+	data JSONB,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_games_created_at ON games(created_at);
+CREATE INDEX IF NOT EXISTS idx_players_games_won ON players(games_won DESC);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_type ON analytics_events(event_type);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_created_at ON analytics_events(created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_games_bot_difficulty;
+
+ALTER TABLE games DROP COLUMN IF EXISTS bot_difficulty;
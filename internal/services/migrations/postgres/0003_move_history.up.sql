@@ -0,0 +1 @@
+ALTER TABLE games ADD COLUMN IF NOT EXISTS move_history JSONB NOT NULL DEFAULT '[]';
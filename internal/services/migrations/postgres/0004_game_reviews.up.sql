@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS game_reviews (
+	game_id VARCHAR(36) PRIMARY KEY,
+	player1 VARCHAR(100) NOT NULL,
+	player2 VARCHAR(100) NOT NULL,
+	player1_accuracy DECIMAL(5,1),
+	player2_accuracy DECIMAL(5,1),
+	review JSONB NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_game_reviews_player1 ON game_reviews(player1);
+CREATE INDEX IF NOT EXISTS idx_game_reviews_player2 ON game_reviews(player2);
@@ -0,0 +1,7 @@
+ALTER TABLE players DROP COLUMN IF EXISTS best_streak;
+ALTER TABLE players DROP COLUMN IF EXISTS current_streak;
+ALTER TABLE players DROP COLUMN IF EXISTS abandonments;
+ALTER TABLE players DROP COLUMN IF EXISTS games_vs_human;
+ALTER TABLE players DROP COLUMN IF EXISTS games_vs_bot;
+ALTER TABLE players DROP COLUMN IF EXISTS games_drawn;
+ALTER TABLE players DROP COLUMN IF EXISTS games_lost;
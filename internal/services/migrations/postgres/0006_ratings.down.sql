@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_players_bot_rating;
+DROP INDEX IF EXISTS idx_players_pvp_rating;
+
+ALTER TABLE players DROP COLUMN IF EXISTS bot_volatility;
+ALTER TABLE players DROP COLUMN IF EXISTS bot_rating_deviation;
+ALTER TABLE players DROP COLUMN IF EXISTS bot_rating;
+ALTER TABLE players DROP COLUMN IF EXISTS pvp_volatility;
+ALTER TABLE players DROP COLUMN IF EXISTS pvp_rating_deviation;
+ALTER TABLE players DROP COLUMN IF EXISTS pvp_rating;
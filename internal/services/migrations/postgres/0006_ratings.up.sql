@@ -0,0 +1,9 @@
+ALTER TABLE players ADD COLUMN IF NOT EXISTS pvp_rating DOUBLE PRECISION NOT NULL DEFAULT 1500;
+ALTER TABLE players ADD COLUMN IF NOT EXISTS pvp_rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350;
+ALTER TABLE players ADD COLUMN IF NOT EXISTS pvp_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06;
+ALTER TABLE players ADD COLUMN IF NOT EXISTS bot_rating DOUBLE PRECISION NOT NULL DEFAULT 1500;
+ALTER TABLE players ADD COLUMN IF NOT EXISTS bot_rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350;
+ALTER TABLE players ADD COLUMN IF NOT EXISTS bot_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06;
+
+CREATE INDEX IF NOT EXISTS idx_players_pvp_rating ON players(pvp_rating DESC) WHERE games_vs_human > 0;
+CREATE INDEX IF NOT EXISTS idx_players_bot_rating ON players(bot_rating DESC) WHERE games_vs_bot > 0;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_games_end_reason;
+
+ALTER TABLE games DROP COLUMN IF EXISTS end_reason;
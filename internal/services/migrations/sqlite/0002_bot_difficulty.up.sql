@@ -0,0 +1,3 @@
+ALTER TABLE games ADD COLUMN bot_difficulty VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_games_bot_difficulty ON games(bot_difficulty) WHERE is_bot;
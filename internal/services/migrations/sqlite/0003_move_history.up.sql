@@ -0,0 +1 @@
+ALTER TABLE games ADD COLUMN move_history TEXT NOT NULL DEFAULT '[]';
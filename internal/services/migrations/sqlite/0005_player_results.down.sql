@@ -0,0 +1,7 @@
+ALTER TABLE players DROP COLUMN best_streak;
+ALTER TABLE players DROP COLUMN current_streak;
+ALTER TABLE players DROP COLUMN abandonments;
+ALTER TABLE players DROP COLUMN games_vs_human;
+ALTER TABLE players DROP COLUMN games_vs_bot;
+ALTER TABLE players DROP COLUMN games_drawn;
+ALTER TABLE players DROP COLUMN games_lost;
@@ -0,0 +1,61 @@
+ALTER TABLE players ADD COLUMN games_lost INTEGER DEFAULT 0;
+ALTER TABLE players ADD COLUMN games_drawn INTEGER DEFAULT 0;
+ALTER TABLE players ADD COLUMN games_vs_bot INTEGER DEFAULT 0;
+ALTER TABLE players ADD COLUMN games_vs_human INTEGER DEFAULT 0;
+ALTER TABLE players ADD COLUMN abandonments INTEGER DEFAULT 0;
+ALTER TABLE players ADD COLUMN current_streak INTEGER DEFAULT 0;
+ALTER TABLE players ADD COLUMN best_streak INTEGER DEFAULT 0;
+
+-- Rebuild the counters from game history: previously only winners were
+-- recorded, and bot wins gave the bot a leaderboard entry of its own.
+INSERT INTO players (username, games_played, games_won, games_lost, games_drawn, games_vs_bot, games_vs_human, total_duration, last_played)
+SELECT
+	username,
+	COUNT(*),
+	SUM(won),
+	SUM(lost),
+	SUM(drawn),
+	SUM(vs_bot),
+	COUNT(*) - SUM(vs_bot),
+	SUM(duration),
+	MAX(finished_at)
+FROM (
+	SELECT
+		player1 AS username,
+		CASE WHEN winner = 1 THEN 1 ELSE 0 END AS won,
+		CASE WHEN winner = 2 THEN 1 ELSE 0 END AS lost,
+		CASE WHEN winner IS NULL THEN 1 ELSE 0 END AS drawn,
+		CASE WHEN is_bot THEN 1 ELSE 0 END AS vs_bot,
+		duration,
+		finished_at
+	FROM games
+	UNION ALL
+	SELECT
+		player2,
+		CASE WHEN winner = 2 THEN 1 ELSE 0 END,
+		CASE WHEN winner = 1 THEN 1 ELSE 0 END,
+		CASE WHEN winner IS NULL THEN 1 ELSE 0 END,
+		0,
+		duration,
+		finished_at
+	FROM games
+	WHERE NOT is_bot
+) results
+WHERE TRUE
+GROUP BY username
+ON CONFLICT (username) DO UPDATE SET
+	games_played = EXCLUDED.games_played,
+	games_won = EXCLUDED.games_won,
+	games_lost = EXCLUDED.games_lost,
+	games_drawn = EXCLUDED.games_drawn,
+	games_vs_bot = EXCLUDED.games_vs_bot,
+	games_vs_human = EXCLUDED.games_vs_human,
+	total_duration = EXCLUDED.total_duration,
+	last_played = EXCLUDED.last_played;
+
+DELETE FROM players
+WHERE username NOT IN (
+	SELECT player1 FROM games
+	UNION
+	SELECT player2 FROM games WHERE NOT is_bot
+);
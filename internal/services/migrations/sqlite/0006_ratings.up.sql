@@ -0,0 +1,9 @@
+ALTER TABLE players ADD COLUMN pvp_rating REAL NOT NULL DEFAULT 1500;
+ALTER TABLE players ADD COLUMN pvp_rating_deviation REAL NOT NULL DEFAULT 350;
+ALTER TABLE players ADD COLUMN pvp_volatility REAL NOT NULL DEFAULT 0.06;
+ALTER TABLE players ADD COLUMN bot_rating REAL NOT NULL DEFAULT 1500;
+ALTER TABLE players ADD COLUMN bot_rating_deviation REAL NOT NULL DEFAULT 350;
+ALTER TABLE players ADD COLUMN bot_volatility REAL NOT NULL DEFAULT 0.06;
+
+CREATE INDEX IF NOT EXISTS idx_players_pvp_rating ON players(pvp_rating DESC) WHERE games_vs_human > 0;
+CREATE INDEX IF NOT EXISTS idx_players_bot_rating ON players(bot_rating DESC) WHERE games_vs_bot > 0;
@@ -0,0 +1,5 @@
+-- Which side the bot played; NULL for games between two people. Bot games
+-- before rematches always had the bot moving second.
+ALTER TABLE games ADD COLUMN bot_seat INTEGER;
+
+UPDATE games SET bot_seat = 2 WHERE is_bot AND bot_seat IS NULL;
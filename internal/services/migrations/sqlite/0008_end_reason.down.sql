@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_games_end_reason;
+
+ALTER TABLE games DROP COLUMN end_reason;
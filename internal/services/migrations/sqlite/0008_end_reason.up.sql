@@ -0,0 +1,5 @@
+-- How a game finished: connect-four, resign, draw-agreed, board-full,
+-- timeout or abandonment. NULL for games recorded before it was tracked.
+ALTER TABLE games ADD COLUMN end_reason VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_games_end_reason ON games(end_reason);
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"emitrr-4-in-a-row/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStorage is an embedded Storage backend for running without a
+// database server, e.g. locally or in tests. Use ":memory:" as the path for
+// a throwaway database.
+type SQLiteStorage struct {
+	db  *sql.DB
+	cfg *config.Config
+}
+
+func NewSQLiteStorage(cfg *config.Config) *SQLiteStorage {
+	return &SQLiteStorage{cfg: cfg}
+}
+
+// Initialize opens the database file and applies any pending migrations.
+func (s *SQLiteStorage) Initialize() error {
+	if err := s.Connect(); err != nil {
+		return err
+	}
+	return s.Migrate()
+}
+
+func (s *SQLiteStorage) Connect() error {
+	path := s.cfg.SQLitePath
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return err
+	}
+
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY
+	// between our own goroutines and keeps ":memory:" databases shared.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	return nil
+}
+
+// Migrations need no cross-process lock: the database file belongs to this
+// process and the single connection serializes everything else.
+func (s *SQLiteStorage) Migrate() error {
+	return s.migrator().up()
+}
+
+func (s *SQLiteStorage) MigrateDown(steps int) error {
+	return s.migrator().down(steps)
+}
+
+func (s *SQLiteStorage) MigrationStatus() ([]MigrationStatus, error) {
+	return s.migrator().status()
+}
+
+func (s *SQLiteStorage) migrator() *migrator {
+	return &migrator{db: s.db, dialect: "sqlite"}
+}
+
+func (s *SQLiteStorage) SaveGame(gameData GameData) error {
+	return saveGame(s.db, gameData)
+}
+
+func (s *SQLiteStorage) GetGame(gameID string) (*GameRecord, error) {
+	return getGame(s.db, gameID)
+}
+
+func (s *SQLiteStorage) GetPlayerStats(username string) (*PlayerStats, error) {
+	query := `
+		SELECT
+			username, games_played, games_won, games_lost, games_drawn,
+			games_vs_bot, games_vs_human, abandonments, current_streak, best_streak, total_duration,
+			ROUND(games_won * 100.0 / MAX(games_played, 1), 1) as win_rate,
+			last_played,
+			pvp_rating, pvp_rating_deviation, bot_rating, bot_rating_deviation
+		FROM players
+		WHERE username = $1
+	`
+
+	return getPlayerStats(s.db, query, username)
+}
+
+func (s *SQLiteStorage) SaveGameReview(review GameReviewData) error {
+	return saveGameReview(s.db, review)
+}
+
+func (s *SQLiteStorage) GetGameReview(gameID string) (json.RawMessage, error) {
+	return getGameReview(s.db, gameID)
+}
+
+func (s *SQLiteStorage) GetPlayerAccuracy(username string) (PlayerAccuracy, error) {
+	return getPlayerAccuracy(s.db, username)
+}
+
+// GetLeaderboard ranks players by PvP rating, vs-bot rating or raw wins.
+func (s *SQLiteStorage) GetLeaderboard(sort string, limit int) ([]PlayerStats, error) {
+	where, order := leaderboardOrder(sort)
+	query := `
+		SELECT
+			username, games_played, games_won, games_lost, games_drawn,
+			games_vs_bot, games_vs_human, abandonments, current_streak, best_streak, total_duration,
+			ROUND(games_won * 100.0 / MAX(games_played, 1), 1) as win_rate,
+			last_played,
+			pvp_rating, pvp_rating_deviation, bot_rating, bot_rating_deviation
+		FROM players
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT $1
+	`
+
+	return queryLeaderboard(s.db, query, limit)
+}
+
+func (s *SQLiteStorage) GetBotLeaderboard(difficulty string, limit int) ([]PlayerStats, error) {
+	query := `
+		SELECT
+			CASE WHEN bot_seat = 1 THEN player2 ELSE player1 END as player,
+			COUNT(*) as games_played,
+			COUNT(*) FILTER (WHERE winner <> bot_seat) as games_won,
+			COUNT(*) FILTER (WHERE winner = bot_seat) as games_lost,
+			COUNT(*) FILTER (WHERE winner IS NULL) as games_drawn,
+			COUNT(*) as games_vs_bot,
+			0 as games_vs_human,
+			0 as abandonments,
+			0 as current_streak,
+			0 as best_streak,
+			SUM(duration) as total_duration,
+			ROUND(COUNT(*) FILTER (WHERE winner <> bot_seat) * 100.0 / COUNT(*), 1) as win_rate,
+			MAX(finished_at) as last_played,
+			NULL, NULL, NULL, NULL
+		FROM games
+		WHERE is_bot AND bot_difficulty = $1
+		GROUP BY player
+		ORDER BY games_won DESC, win_rate DESC, games_played DESC
+		LIMIT $2
+	`
+
+	leaderboard, err := queryLeaderboard(s.db, query, difficulty, limit)
+	for i := range leaderboard {
+		leaderboard[i].BotDifficulty = difficulty
+	}
+	return leaderboard, err
+}
+
+func (s *SQLiteStorage) GetAnalytics() (Analytics, error) {
+	return getAnalytics(s.db, `
+		SELECT
+			DATE(created_at) as date,
+			COUNT(*) as games
+		FROM games
+		WHERE created_at >= DATE('now', '-7 days')
+		GROUP BY DATE(created_at)
+		ORDER BY date DESC
+	`)
+}
+
+func (s *SQLiteStorage) SaveAnalyticsEvent(eventType, gameID, player string, data map[string]interface{}) error {
+	return saveAnalyticsEvent(s.db, eventType, gameID, player, data)
+}
+
+func (s *SQLiteStorage) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
@@ -0,0 +1,630 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"emitrr-4-in-a-row/internal/config"
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/rating"
+)
+
+// Storage persists finished games, player stats, reviews and analytics.
+// DatabaseService (Postgres) and SQLiteStorage implement it.
+type Storage interface {
+	Initialize() error
+	Connect() error
+	Close() error
+
+	Migrate() error
+	MigrateDown(steps int) error
+	MigrationStatus() ([]MigrationStatus, error)
+
+	SaveGame(gameData GameData) error
+	GetGame(gameID string) (*GameRecord, error)
+	GetPlayerStats(username string) (*PlayerStats, error)
+	SaveGameReview(review GameReviewData) error
+	GetGameReview(gameID string) (json.RawMessage, error)
+	GetPlayerAccuracy(username string) (PlayerAccuracy, error)
+	GetLeaderboard(sort string, limit int) ([]PlayerStats, error)
+	GetBotLeaderboard(difficulty string, limit int) ([]PlayerStats, error)
+	GetAnalytics() (Analytics, error)
+	SaveAnalyticsEvent(eventType, gameID, player string, data map[string]interface{}) error
+}
+
+// Leaderboard orderings
+const (
+	LeaderboardByRating    = "rating"
+	LeaderboardByBotRating = "bot_rating"
+	LeaderboardByWins      = "wins"
+)
+
+// NewStorage returns the backend selected by STORAGE_DRIVER.
+func NewStorage(cfg *config.Config) Storage {
+	switch cfg.StorageDriver {
+	case "sqlite":
+		return NewSQLiteStorage(cfg)
+	default:
+		return NewDatabaseService(cfg)
+	}
+}
+
+// The helpers below hold the SQL both backends share; queries that differ
+// between dialects are passed in by the caller.
+
+// saveGame inserts the game and applies every player's result in one
+// transaction, so the games and players tables never disagree.
+func saveGame(db *sql.DB, gameData GameData) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	moveHistory := gameData.MoveHistory
+	if moveHistory == nil {
+		moveHistory = []models.Move{}
+	}
+	moveHistoryJSON, err := json.Marshal(moveHistory)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO games (id, player1, player2, winner, duration, moves, is_bot, created_at, bot_difficulty, move_history, bot_seat, end_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, NULLIF($11, 0), NULLIF($12, ''))
+	`
+
+	_, err = tx.Exec(query,
+		gameData.ID,
+		gameData.Player1,
+		gameData.Player2,
+		gameData.Winner,
+		gameData.Duration,
+		gameData.Moves,
+		gameData.IsBot,
+		gameData.CreatedAt,
+		gameData.BotDifficulty,
+		string(moveHistoryJSON),
+		gameData.BotSeat,
+		gameData.EndReason,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range gameData.Results {
+		if err := updatePlayerStats(tx, result, gameData.Duration); err != nil {
+			return err
+		}
+	}
+
+	// The stats upserts above hold the players' row locks, so no other game
+	// can move these ratings between reading and writing them
+	if err := updateRatings(tx, gameData.Results); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func getGame(db *sql.DB, gameID string) (*GameRecord, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, player1, player2, winner, duration, moves, is_bot,
+			COALESCE(bot_difficulty, ''), COALESCE(bot_seat, 0), COALESCE(end_reason, ''),
+			created_at, finished_at, move_history
+		FROM games
+		WHERE id = $1
+	`
+
+	var record GameRecord
+	var winner sql.NullInt64
+	var createdAt, finishedAt dbTime
+	var moveHistory string
+	err := db.QueryRow(query, gameID).Scan(
+		&record.ID,
+		&record.Player1,
+		&record.Player2,
+		&winner,
+		&record.Duration,
+		&record.Moves,
+		&record.IsBot,
+		&record.BotDifficulty,
+		&record.BotSeat,
+		&record.EndReason,
+		&createdAt,
+		&finishedAt,
+		&moveHistory,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if winner.Valid {
+		w := int(winner.Int64)
+		record.Winner = &w
+	}
+	record.CreatedAt = createdAt.Time
+	if finishedAt.Valid {
+		record.FinishedAt = &finishedAt.Time
+	}
+	if err := json.Unmarshal([]byte(moveHistory), &record.MoveHistory); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func updatePlayerStats(tx *sql.Tx, result PlayerResult, duration int) error {
+	flag := func(set bool) int {
+		if set {
+			return 1
+		}
+		return 0
+	}
+
+	query := `
+		INSERT INTO players (
+			username, games_played, games_won, games_lost, games_drawn, games_vs_bot, games_vs_human,
+			abandonments, current_streak, best_streak, total_duration, last_played
+		)
+		VALUES ($1, 1, $2, $3, $4, $5, $6, $7, $2, $2, $8, CURRENT_TIMESTAMP)
+		ON CONFLICT (username)
+		DO UPDATE SET
+			games_played = players.games_played + 1,
+			games_won = players.games_won + EXCLUDED.games_won,
+			games_lost = players.games_lost + EXCLUDED.games_lost,
+			games_drawn = players.games_drawn + EXCLUDED.games_drawn,
+			games_vs_bot = players.games_vs_bot + EXCLUDED.games_vs_bot,
+			games_vs_human = players.games_vs_human + EXCLUDED.games_vs_human,
+			abandonments = players.abandonments + EXCLUDED.abandonments,
+			current_streak = CASE WHEN EXCLUDED.games_won = 1 THEN players.current_streak + 1 ELSE 0 END,
+			best_streak = CASE
+				WHEN EXCLUDED.games_won = 1 AND players.current_streak + 1 > players.best_streak
+				THEN players.current_streak + 1
+				ELSE players.best_streak
+			END,
+			total_duration = players.total_duration + EXCLUDED.total_duration,
+			last_played = CURRENT_TIMESTAMP
+	`
+
+	_, err := tx.Exec(query,
+		result.Username,
+		flag(result.Result == ResultWin),
+		flag(result.Result == ResultLoss),
+		flag(result.Result == ResultDraw),
+		flag(result.VsBot),
+		flag(!result.VsBot),
+		flag(result.Abandoned),
+		duration,
+	)
+	return err
+}
+
+// updateRatings applies one Glicko-2 rating period per rated result, using
+// every player's rating from before the game.
+func updateRatings(tx *sql.Tx, results []PlayerResult) error {
+	pool := func(result PlayerResult) string {
+		if result.VsBot {
+			return "bot"
+		}
+		return "pvp"
+	}
+
+	current := make(map[string]rating.Rating)
+	for _, result := range results {
+		if !result.Rated {
+			continue
+		}
+		var r rating.Rating
+		query := fmt.Sprintf(`SELECT %[1]s_rating, %[1]s_rating_deviation, %[1]s_volatility FROM players WHERE username = $1`, pool(result))
+		if err := tx.QueryRow(query, result.Username).Scan(&r.Rating, &r.Deviation, &r.Volatility); err != nil {
+			return err
+		}
+		current[result.Username] = r
+	}
+
+	for _, result := range results {
+		if !result.Rated {
+			continue
+		}
+
+		opponent := result.OpponentRating
+		if !result.VsBot {
+			var ok bool
+			if opponent, ok = current[result.Opponent]; !ok {
+				continue
+			}
+		}
+
+		score := rating.Draw
+		switch result.Result {
+		case ResultWin:
+			score = rating.Win
+		case ResultLoss:
+			score = rating.Loss
+		}
+
+		updated := current[result.Username].Update(rating.Result{Opponent: opponent, Score: score})
+		query := fmt.Sprintf(`
+			UPDATE players
+			SET %[1]s_rating = $2, %[1]s_rating_deviation = $3, %[1]s_volatility = $4
+			WHERE username = $1
+		`, pool(result))
+		if _, err := tx.Exec(query, result.Username, updated.Rating, updated.Deviation, updated.Volatility); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveGameReview(db *sql.DB, review GameReviewData) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	reviewJSON, err := json.Marshal(review.Review)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO game_reviews (game_id, player1, player2, player1_accuracy, player2_accuracy, review)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (game_id)
+		DO UPDATE SET
+			player1_accuracy = EXCLUDED.player1_accuracy,
+			player2_accuracy = EXCLUDED.player2_accuracy,
+			review = EXCLUDED.review
+	`
+
+	_, err = db.Exec(query,
+		review.GameID,
+		review.Player1,
+		review.Player2,
+		review.Player1Accuracy,
+		review.Player2Accuracy,
+		string(reviewJSON),
+	)
+	return err
+}
+
+func getGameReview(db *sql.DB, gameID string) (json.RawMessage, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var review string
+	err := db.QueryRow(`SELECT review FROM game_reviews WHERE game_id = $1`, gameID).Scan(&review)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(review), nil
+}
+
+func getPlayerAccuracy(db *sql.DB, username string) (PlayerAccuracy, error) {
+	accuracy := PlayerAccuracy{Username: username}
+	if db == nil {
+		return accuracy, nil
+	}
+
+	query := `
+		SELECT
+			COUNT(*),
+			COALESCE(ROUND(AVG(accuracy), 1), 0),
+			COALESCE(MAX(accuracy), 0)
+		FROM (
+			SELECT player1_accuracy as accuracy FROM game_reviews WHERE player1 = $1 AND player1_accuracy IS NOT NULL
+			UNION ALL
+			SELECT player2_accuracy as accuracy FROM game_reviews WHERE player2 = $1 AND player2_accuracy IS NOT NULL
+		) reviewed
+	`
+
+	err := db.QueryRow(query, username).Scan(&accuracy.GamesReviewed, &accuracy.AvgAccuracy, &accuracy.BestAccuracy)
+	return accuracy, err
+}
+
+// leaderboardOrder returns the WHERE and ORDER BY clauses for a leaderboard
+// sort. Rated boards only list players with games in that pool.
+func leaderboardOrder(sort string) (string, string) {
+	switch sort {
+	case LeaderboardByWins:
+		return "games_played > 0", "games_won DESC, win_rate DESC, games_played DESC"
+	case LeaderboardByBotRating:
+		return "games_vs_bot > 0", "bot_rating DESC, games_vs_bot DESC"
+	default:
+		return "games_vs_human > 0", "pvp_rating DESC, games_vs_human DESC"
+	}
+}
+
+// scanPlayerStats reads a row of username, games_played, games_won,
+// games_lost, games_drawn, games_vs_bot, games_vs_human, abandonments,
+// current_streak, best_streak, total_duration, win_rate, last_played and the
+// (possibly NULL) pvp_rating, pvp_rating_deviation, bot_rating and
+// bot_rating_deviation.
+func scanPlayerStats(row interface{ Scan(...interface{}) error }) (PlayerStats, error) {
+	var stats PlayerStats
+	var lastPlayed dbTime
+	var pvpRating, pvpDeviation, botRating, botDeviation sql.NullFloat64
+	err := row.Scan(
+		&stats.Username,
+		&stats.GamesPlayed,
+		&stats.GamesWon,
+		&stats.GamesLost,
+		&stats.GamesDrawn,
+		&stats.GamesVsBot,
+		&stats.GamesVsHuman,
+		&stats.Abandonments,
+		&stats.CurrentStreak,
+		&stats.BestStreak,
+		&stats.TotalDuration,
+		&stats.WinRate,
+		&lastPlayed,
+		&pvpRating,
+		&pvpDeviation,
+		&botRating,
+		&botDeviation,
+	)
+	stats.LastPlayed = lastPlayed.Format("2006-01-02 15:04:05")
+	if pvpRating.Valid {
+		stats.Rating = math.Round(pvpRating.Float64)
+		stats.RatingDeviation = math.Round(pvpDeviation.Float64)
+		stats.Provisional = pvpDeviation.Float64 > rating.ProvisionalDeviation
+	}
+	if botRating.Valid {
+		stats.BotRating = math.Round(botRating.Float64)
+		stats.BotRatingDeviation = math.Round(botDeviation.Float64)
+		stats.BotProvisional = botDeviation.Float64 > rating.ProvisionalDeviation
+	}
+	return stats, err
+}
+
+// queryLeaderboard runs a query returning scanPlayerStats rows.
+func queryLeaderboard(db *sql.DB, query string, args ...interface{}) ([]PlayerStats, error) {
+	if db == nil {
+		return []PlayerStats{}, nil
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return []PlayerStats{}, err
+	}
+	defer rows.Close()
+
+	var leaderboard []PlayerStats
+	for rows.Next() {
+		stats, err := scanPlayerStats(rows)
+		if err != nil {
+			continue
+		}
+		leaderboard = append(leaderboard, stats)
+	}
+
+	return leaderboard, nil
+}
+
+// getPlayerStats looks up one player with a query returning a
+// scanPlayerStats row, or nil for unknown players.
+func getPlayerStats(db *sql.DB, query, username string) (*PlayerStats, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	stats, err := scanPlayerStats(db.QueryRow(query, username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// getAnalytics builds the dashboard; gamesPerDayQuery returns date and games
+// rows for the last week.
+func getAnalytics(db *sql.DB, gamesPerDayQuery string) (Analytics, error) {
+	analytics := Analytics{}
+
+	if db == nil {
+		return analytics, nil
+	}
+
+	queries := map[string]string{
+		"totalGames":      "SELECT COUNT(*) as count FROM games",
+		"totalPlayers":    "SELECT COUNT(*) as count FROM players WHERE games_played > 0",
+		"avgGameDuration": "SELECT ROUND(AVG(duration), 1) as avg_duration FROM games",
+		"gamesPerDay":     gamesPerDayQuery,
+		"topWinners": `
+			SELECT username, games_won
+			FROM players
+			WHERE games_played > 0
+			ORDER BY games_won DESC
+			LIMIT 5
+		`,
+		"botVsHuman": `
+			SELECT
+				is_bot,
+				COUNT(*) as count,
+				ROUND(AVG(duration), 1) as avg_duration
+			FROM games
+			GROUP BY is_bot
+		`,
+		"endReasons": `
+			SELECT end_reason, COUNT(*) as count
+			FROM games
+			WHERE end_reason IS NOT NULL
+			GROUP BY end_reason
+			ORDER BY count DESC
+		`,
+	}
+
+	// Total Games
+	if rows, err := db.Query(queries["totalGames"]); err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var count int
+			rows.Scan(&count)
+			analytics.TotalGames = append(analytics.TotalGames, map[string]interface{}{"count": count})
+		}
+	}
+
+	// Total Players
+	if rows, err := db.Query(queries["totalPlayers"]); err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var count int
+			rows.Scan(&count)
+			analytics.TotalPlayers = append(analytics.TotalPlayers, map[string]interface{}{"count": count})
+		}
+	}
+
+	// Average Game Duration
+	if rows, err := db.Query(queries["avgGameDuration"]); err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var avgDuration sql.NullFloat64
+			rows.Scan(&avgDuration)
+			if avgDuration.Valid {
+				analytics.AvgGameDuration = append(analytics.AvgGameDuration, map[string]interface{}{"avg_duration": avgDuration.Float64})
+			}
+		}
+	}
+
+	// Games Per Day
+	if rows, err := db.Query(queries["gamesPerDay"]); err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var date dbTime
+			var games int
+			rows.Scan(&date, &games)
+			analytics.GamesPerDay = append(analytics.GamesPerDay, map[string]interface{}{
+				"date":  date.Format("2006-01-02"),
+				"games": games,
+			})
+		}
+	}
+
+	// Top Winners
+	if rows, err := db.Query(queries["topWinners"]); err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var username string
+			var gamesWon int
+			rows.Scan(&username, &gamesWon)
+			analytics.TopWinners = append(analytics.TopWinners, map[string]interface{}{
+				"username":  username,
+				"games_won": gamesWon,
+			})
+		}
+	}
+
+	// Bot vs Human
+	if rows, err := db.Query(queries["botVsHuman"]); err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var isBot bool
+			var count int
+			var avgDuration sql.NullFloat64
+			rows.Scan(&isBot, &count, &avgDuration)
+			analytics.BotVsHuman = append(analytics.BotVsHuman, map[string]interface{}{
+				"is_bot":       isBot,
+				"count":        count,
+				"avg_duration": avgDuration.Float64,
+			})
+		}
+	}
+
+	// How games finished
+	if rows, err := db.Query(queries["endReasons"]); err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var reason string
+			var count int
+			rows.Scan(&reason, &count)
+			analytics.EndReasons = append(analytics.EndReasons, map[string]interface{}{
+				"end_reason": reason,
+				"count":      count,
+			})
+		}
+	}
+
+	return analytics, nil
+}
+
+func saveAnalyticsEvent(db *sql.DB, eventType, gameID, player string, data map[string]interface{}) error {
+	if db == nil {
+		return nil
+	}
+
+	query := `
+		INSERT INTO analytics_events (event_type, game_id, player, data)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	dataJSON := []byte("{}")
+	if data != nil {
+		var err error
+		if dataJSON, err = json.Marshal(data); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(query, eventType, gameID, player, string(dataJSON))
+	return err
+}
+
+// dbTime scans timestamps from either backend: Postgres returns time.Time,
+// SQLite returns text for computed columns such as MAX(finished_at).
+type dbTime struct {
+	time.Time
+	Valid bool
+}
+
+var dbTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+func (t *dbTime) Scan(value interface{}) error {
+	t.Time, t.Valid = time.Time{}, false
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into a timestamp", value)
+}
+
+func (t *dbTime) parse(value string) error {
+	for _, layout := range dbTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid timestamp %q", value)
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"emitrr-4-in-a-row/internal/config"
+	"emitrr-4-in-a-row/internal/models"
+	"emitrr-4-in-a-row/internal/rating"
+)
+
+// newTestStorage opens an empty SQLite database in a temporary directory
+// with every migration applied.
+func newTestStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	storage := NewSQLiteStorage(&config.Config{SQLitePath: filepath.Join(t.TempDir(), "test.db")})
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func TestPlayerResultsBackfill(t *testing.T) {
+	storage := newTestStorage(t)
+
+	// Back to the schema before 0005_player_results
+	if err := storage.MigrateDown(4); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+
+	// Rows as the baseline wrote them: bots always second, and only the
+	// winner of a game, bot included, counted in players
+	seed := []string{
+		`INSERT INTO games (id, player1, player2, winner, duration, moves, is_bot) VALUES
+			('g1', 'alice', 'bob', 1, 60, 7, FALSE),
+			('g2', 'alice', 'AI Bot', 2, 30, 8, TRUE),
+			('g3', 'bob', 'alice', NULL, 90, 42, FALSE),
+			('g4', 'carol', 'AI Bot', 1, 40, 9, TRUE)`,
+		`INSERT INTO players (username, games_played, games_won, total_duration) VALUES
+			('alice', 1, 1, 60),
+			('AI Bot', 1, 1, 30),
+			('carol', 1, 1, 40),
+			('dave', 3, 3, 100)`,
+	}
+	for _, query := range seed {
+		if _, err := storage.db.Exec(query); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	if err := storage.Migrate(); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	want := map[string]PlayerStats{
+		"alice": {GamesPlayed: 3, GamesWon: 1, GamesLost: 1, GamesDrawn: 1, GamesVsBot: 1, GamesVsHuman: 2, TotalDuration: 180},
+		"bob":   {GamesPlayed: 2, GamesLost: 1, GamesDrawn: 1, GamesVsHuman: 2, TotalDuration: 150},
+		"carol": {GamesPlayed: 1, GamesWon: 1, GamesVsBot: 1, TotalDuration: 40},
+	}
+	for username, w := range want {
+		stats, err := storage.GetPlayerStats(username)
+		if err != nil || stats == nil {
+			t.Fatalf("%s: stats %v, err %v", username, stats, err)
+		}
+		got := PlayerStats{
+			GamesPlayed:   stats.GamesPlayed,
+			GamesWon:      stats.GamesWon,
+			GamesLost:     stats.GamesLost,
+			GamesDrawn:    stats.GamesDrawn,
+			GamesVsBot:    stats.GamesVsBot,
+			GamesVsHuman:  stats.GamesVsHuman,
+			TotalDuration: stats.TotalDuration,
+		}
+		if got != w {
+			t.Errorf("%s: %+v, want %+v", username, got, w)
+		}
+	}
+
+	// Neither the bot nor players without a recorded game survive
+	for _, username := range []string{"AI Bot", "dave"} {
+		if stats, err := storage.GetPlayerStats(username); err != nil || stats != nil {
+			t.Errorf("%s: stats %+v, err %v, want no row", username, stats, err)
+		}
+	}
+}
+
+// testGames are a rated PvP game alice won against bob and a bot game carol
+// won against the strong bot.
+func testGames(now time.Time) []GameData {
+	first := 1
+	return []GameData{
+		{
+			ID:        "pvp-1",
+			Player1:   "alice",
+			Player2:   "bob",
+			Winner:    &first,
+			Duration:  60,
+			Moves:     7,
+			EndReason: "connect-four",
+			MoveHistory: []models.Move{
+				{Player: 1, Row: 5, Column: 3, Timestamp: now},
+				{Player: 2, Row: 4, Column: 3, Timestamp: now.Add(time.Second)},
+			},
+			CreatedAt: now,
+			Results: []PlayerResult{
+				{Username: "alice", Result: ResultWin, Rated: true, Opponent: "bob"},
+				{Username: "bob", Result: ResultLoss, Rated: true, Opponent: "alice"},
+			},
+		},
+		{
+			ID:            "bot-1",
+			Player1:       "carol",
+			Player2:       "AI Bot",
+			Winner:        &first,
+			Duration:      40,
+			Moves:         9,
+			IsBot:         true,
+			BotDifficulty: "strong",
+			BotSeat:       2,
+			EndReason:     "connect-four",
+			CreatedAt:     now,
+			Results: []PlayerResult{
+				{Username: "carol", Result: ResultWin, VsBot: true, Rated: true, OpponentRating: rating.Rating{Rating: 1800, Deviation: 50, Volatility: 0.06}},
+			},
+		},
+	}
+}
+
+func usernames(stats []PlayerStats) []string {
+	names := make([]string, len(stats))
+	for i, s := range stats {
+		names[i] = s.Username
+	}
+	return names
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	storage := newTestStorage(t)
+	for _, game := range testGames(time.Now()) {
+		if err := storage.SaveGame(game); err != nil {
+			t.Fatalf("save %s: %v", game.ID, err)
+		}
+	}
+
+	record, err := storage.GetGame("pvp-1")
+	if err != nil || record == nil {
+		t.Fatalf("get game: %v, %v", record, err)
+	}
+	if record.Winner == nil || *record.Winner != 1 || record.EndReason != "connect-four" || len(record.MoveHistory) != 2 || record.MoveHistory[1].Row != 4 {
+		t.Errorf("game read back as %+v", record)
+	}
+
+	alice, _ := storage.GetPlayerStats("alice")
+	bob, _ := storage.GetPlayerStats("bob")
+	carol, _ := storage.GetPlayerStats("carol")
+	if alice == nil || bob == nil || carol == nil {
+		t.Fatalf("stats missing: %v %v %v", alice, bob, carol)
+	}
+	if alice.GamesWon != 1 || alice.GamesVsHuman != 1 || alice.Rating <= rating.DefaultRating {
+		t.Errorf("alice: %+v", alice)
+	}
+	if bob.GamesLost != 1 || bob.CurrentStreak != 0 || bob.Rating >= rating.DefaultRating {
+		t.Errorf("bob: %+v", bob)
+	}
+	if carol.GamesWon != 1 || carol.GamesVsBot != 1 || carol.BotRating <= rating.DefaultRating {
+		t.Errorf("carol: %+v", carol)
+	}
+	if stats, _ := storage.GetPlayerStats("AI Bot"); stats != nil {
+		t.Errorf("the bot has stats: %+v", stats)
+	}
+
+	leaderboards := []struct {
+		sort string
+		want []string
+	}{
+		{LeaderboardByRating, []string{"alice", "bob"}},
+		{LeaderboardByBotRating, []string{"carol"}},
+		{LeaderboardByWins, []string{"alice", "carol", "bob"}},
+	}
+	for _, lb := range leaderboards {
+		stats, err := storage.GetLeaderboard(lb.sort, 10)
+		if err != nil {
+			t.Fatalf("%s leaderboard: %v", lb.sort, err)
+		}
+		got := usernames(stats)
+		// alice and carol tie on wins
+		if lb.sort == LeaderboardByWins && len(got) == 3 && got[0] == "carol" {
+			got[0], got[1] = got[1], got[0]
+		}
+		if len(got) != len(lb.want) {
+			t.Errorf("%s leaderboard: %v, want %v", lb.sort, got, lb.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != lb.want[i] {
+				t.Errorf("%s leaderboard: %v, want %v", lb.sort, got, lb.want)
+				break
+			}
+		}
+	}
+
+	strong, err := storage.GetBotLeaderboard("strong", 10)
+	if err != nil || len(strong) != 1 || strong[0].Username != "carol" || strong[0].GamesWon != 1 || strong[0].BotDifficulty != "strong" {
+		t.Errorf("strong bot leaderboard: %+v, %v", strong, err)
+	}
+	if beginner, _ := storage.GetBotLeaderboard("beginner", 10); len(beginner) != 0 {
+		t.Errorf("beginner bot leaderboard: %+v, want empty", beginner)
+	}
+
+	analytics, err := storage.GetAnalytics()
+	if err != nil {
+		t.Fatalf("analytics: %v", err)
+	}
+	if len(analytics.TotalGames) != 1 || analytics.TotalGames[0]["count"] != 2 {
+		t.Errorf("total games: %v", analytics.TotalGames)
+	}
+	if len(analytics.TotalPlayers) != 1 || analytics.TotalPlayers[0]["count"] != 3 {
+		t.Errorf("total players: %v", analytics.TotalPlayers)
+	}
+	if len(analytics.BotVsHuman) != 2 || len(analytics.EndReasons) != 1 || analytics.EndReasons[0]["count"] != 2 {
+		t.Errorf("bot vs human %v, end reasons %v", analytics.BotVsHuman, analytics.EndReasons)
+	}
+
+	if err := storage.SaveAnalyticsEvent("game_started", "pvp-1", "alice", map[string]interface{}{"gameType": "pvp"}); err != nil {
+		t.Fatalf("save analytics event: %v", err)
+	}
+	var eventType, data string
+	if err := storage.db.QueryRow(`SELECT event_type, data FROM analytics_events WHERE game_id = 'pvp-1'`).Scan(&eventType, &data); err != nil {
+		t.Fatalf("read analytics event: %v", err)
+	}
+	if eventType != "game_started" || data != `{"gameType":"pvp"}` {
+		t.Errorf("analytics event %s %s", eventType, data)
+	}
+}
+
+func TestSQLiteMigrateDownAndUp(t *testing.T) {
+	storage := newTestStorage(t)
+
+	status, err := storage.MigrationStatus()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if err := storage.MigrateDown(len(status)); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	status, _ = storage.MigrationStatus()
+	for _, migration := range status {
+		if migration.Applied {
+			t.Errorf("%04d_%s still applied after rolling everything back", migration.Version, migration.Name)
+		}
+	}
+
+	if err := storage.Migrate(); err != nil {
+		t.Fatalf("migrate up again: %v", err)
+	}
+	status, _ = storage.MigrationStatus()
+	for _, migration := range status {
+		if !migration.Applied {
+			t.Errorf("%04d_%s not applied", migration.Version, migration.Name)
+		}
+	}
+	for _, game := range testGames(time.Now()) {
+		if err := storage.SaveGame(game); err != nil {
+			t.Errorf("save %s after re-migrating: %v", game.ID, err)
+		}
+	}
+}
@@ -21,10 +21,15 @@ import (
 func main() {
 	cfg := config.Load()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+
 	// Initialize services
-	dbService := services.NewDatabaseService(cfg)
+	dbService := services.NewStorage(cfg)
 	analyticsService := services.NewAnalyticsService(cfg)
-	gameManager := game.NewGameManager(dbService, analyticsService)
+	gameManager := game.NewGameManager(cfg, dbService, analyticsService)
 
 	// Initialize services
 	if err := analyticsService.Initialize(); err != nil {
@@ -84,4 +89,4 @@ func main() {
 	analyticsService.Close()
 	dbService.Close()
 	log.Println("Server exited")
-}
\ No newline at end of file
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"emitrr-4-in-a-row/internal/config"
+	"emitrr-4-in-a-row/internal/services"
+)
+
+// runMigrate handles `migrate [up|down [steps]|status]`.
+func runMigrate(cfg *config.Config, args []string) {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	dbService := services.NewStorage(cfg)
+	if err := dbService.Connect(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbService.Close()
+
+	switch command {
+	case "up":
+		if err := dbService.Migrate(); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		if err := dbService.MigrateDown(steps); err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+	case "status":
+		status, err := dbService.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, migration := range status {
+			state := "pending"
+			if migration.Applied {
+				state = "applied " + migration.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", migration.Version, migration.Name, state)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q (expected up, down or status)", command)
+	}
+}